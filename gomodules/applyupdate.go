@@ -1,14 +1,12 @@
 package gomodules
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -19,42 +17,140 @@ import (
 )
 
 func (u *Updater) ApplyUpdate(ctx context.Context, update updater.Update) error {
-	if MajorPkg(update) {
-		if err := u.updateSourceCode(update); err != nil {
-			return err
-		}
+	if update.Path == IndirectBatchPath && u.IndirectPolicy == IndirectBatch {
+		return u.applyIndirectBatch(ctx, update)
+	}
+	if update.Path == RefreshBatchPath && u.Refresh != "" {
+		return u.applyRefresh(ctx, update)
+	}
+	if update.Path == GoVersionPath && u.GoVersion {
+		return u.applyGoVersion(update)
 	}
 
-	modFiles, err := u.collectGoModFiles()
+	// Only modules that require the path are updated, or replace it with the fork this update was proposed for:
+	modFiles, err := u.requiringGoModFiles(update)
 	if err != nil {
 		return fmt.Errorf("collecting go.mod files: %w", err)
 	}
+	if u.TrackForks {
+		forks, err := u.forkReplacingGoModFiles(ctx, update)
+		if err != nil {
+			return fmt.Errorf("collecting go.mod files: %w", err)
+		}
+		modFiles = mergeGoModFiles(modFiles, forks)
+	}
+
+	if MajorPkg(update) {
+		if err := u.updateMajorSourceCode(ctx, update, modFiles); err != nil {
+			return err
+		}
+	}
 	for _, f := range modFiles {
 		logrus.WithField("path", f).Debug("updating go.mod file")
 		if err := u.updateGoModule(ctx, f, update); err != nil {
 			return err
 		}
 	}
+
+	work, err := u.parseGoWork()
+	if err != nil {
+		return err
+	} else if work != nil {
+		logrus.Debug("updating go.work file")
+		if err := u.updateGoWork(ctx, work, update); err != nil {
+			return fmt.Errorf("updating go.work: %w", err)
+		}
+	}
+
+	if u.VerifyTools {
+		if err := u.verifyTools(ctx, update, modFiles); err != nil {
+			return fmt.Errorf("verifying tools: %w", err)
+		}
+	}
+	if u.PinnedTools {
+		if err := u.updatePinnedReferences(ctx, update); err != nil {
+			return fmt.Errorf("updating pinned tools: %w", err)
+		}
+	}
 	return nil
 }
 
+// mergeGoModFiles returns the sorted union of go.mod file lists.
+func mergeGoModFiles(a, b []string) []string {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	ret := make([]string, 0, len(a)+len(b))
+	for _, files := range [][]string{a, b} {
+		for _, gomod := range files {
+			if _, ok := seen[gomod]; ok {
+				continue
+			}
+			seen[gomod] = struct{}{}
+			ret = append(ret, gomod)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// updateMajorSourceCode rewrites source code for a major update.
+// If the updated path replaces another module, e.g. a fork, references to the replaced module are rewritten.
+func (u *Updater) updateMajorSourceCode(ctx context.Context, update updater.Update, modFiles []string) error {
+	sourceUpdates := make(map[string]updater.Update, len(modFiles))
+	var replaced bool
+	for _, f := range modFiles {
+		goMod, err := u.parseGoMod(f)
+		if err != nil {
+			return err
+		}
+		sourceUpdate := update
+		if _, rep := activeReplacement(goMod, update.Path); rep != nil {
+			sourceUpdate.Path = rep.Old.Path
+			replaced = true
+		}
+		sourceUpdates[f] = sourceUpdate
+	}
+
+	if replaced {
+		// The replacement must be available at the next major, or the replaced module can't follow it:
+		nextPath := pathMajorVersion(update.Path, semver.Major(update.Next))
+		nfo, err := u.queryModuleVersions(ctx, nextPath, func(v string) bool { return v == update.Next })
+		if (err != nil && strings.Contains(err.Error(), "exit status 1")) || (err == nil && nfo == nil) {
+			return fmt.Errorf("replacement %s has no %s major version at %s", update.Path, semver.Major(update.Next), update.Next)
+		} else if err != nil {
+			return fmt.Errorf("querying replacement major version: %w", err)
+		}
+	}
+
+	for _, f := range modFiles {
+		if err := u.updateSourceCode(sourceUpdates[f], []string{f}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeReplacement returns the requirement of a go.mod file that is replaced by a versioned path, e.g. a fork.
+func activeReplacement(goMod *modfile.File, path string) (*modfile.Require, *modfile.Replace) {
+	for _, req := range goMod.Require {
+		rep := findReplacement(req.Mod.Path, req.Mod.Version, goMod.Replace)
+		if rep != nil && rep.New.Path == path && rep.New.Version != "" {
+			return req, rep
+		}
+	}
+	return nil, nil
+}
+
 func (u *Updater) updateGoModule(ctx context.Context, path string, update updater.Update) error {
-	if err := u.updateGoMod(path, update); err != nil {
+	if err := u.updateGoMod(ctx, path, update); err != nil {
 		return fmt.Errorf("updating go.mod: %w", err)
 	}
 
-	modRoot, _ := filepath.Split(path)
-	if closer, err := ensureGoFileInPath(modRoot); err != nil {
+	goMod, err := u.parseGoMod(path)
+	if err != nil {
 		return err
-	} else if closer != nil {
-		defer func() {
-			if err := closer(); err != nil {
-				logrus.WithError(err).Warn("cleaning up temp go file")
-			}
-		}()
 	}
-
-	if err := u.updateGoSum(ctx, modRoot); err != nil {
+	modRoot, _ := filepath.Split(path)
+	if err := u.updateGoSum(ctx, modRoot, updatedRequirements(goMod, update)); err != nil {
 		return err
 	}
 
@@ -66,7 +162,7 @@ func (u *Updater) updateGoModule(ctx context.Context, path string, update update
 	return nil
 }
 
-func (u *Updater) updateGoMod(path string, update updater.Update) error {
+func (u *Updater) updateGoMod(ctx context.Context, path string, update updater.Update) error {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("reading go.mod: %w", err)
@@ -76,9 +172,28 @@ func (u *Updater) updateGoMod(path string, update updater.Update) error {
 		return fmt.Errorf("parsing go.mod: %w", err)
 	}
 
-	if err := patchParsedGoMod(goMod, update); err != nil {
+	if rep := u.proposedForkReplacement(goMod, update); u.TrackForks && rep != nil {
+		if err := dropForkReplacement(goMod, update, rep); err != nil {
+			return err
+		}
+	} else if err := patchParsedGoMod(goMod, update); err != nil {
 		return err
 	}
+	if u.GoVersionPolicy == GoVersionBump {
+		if err := u.bumpGoDirective(ctx, goMod, update); err != nil {
+			return fmt.Errorf("bumping go directive: %w", err)
+		}
+	}
+	if u.SiblingModules {
+		if err := u.dropSiblingReplace(ctx, goMod, update); err != nil {
+			return fmt.Errorf("dropping local replacement: %w", err)
+		}
+	}
+	if u.DropStaleExcludes {
+		if err := dropStaleExcludes(goMod, update); err != nil {
+			return fmt.Errorf("dropping stale excludes: %w", err)
+		}
+	}
 
 	updated, err := goMod.Format()
 	if err != nil {
@@ -99,16 +214,8 @@ func (u *Updater) updateGoMod(path string, update updater.Update) error {
 }
 
 func patchParsedGoMod(goMod *modfile.File, update updater.Update) error {
-	// TODO: these can be combined (e.g. a major update via replacement)
 	if MajorPkg(update) {
-		if err := goMod.DropRequire(update.Path); err != nil {
-			return fmt.Errorf("dropping major requirement: %w", err)
-		}
-		pkgNext := pathMajorVersion(update.Path, semver.Major(update.Next))
-		if err := goMod.AddRequire(pkgNext, update.Next); err != nil {
-			return fmt.Errorf("adding major requirement: %w", err)
-		}
-		return nil
+		return patchMajorRequirement(goMod, update)
 	}
 
 	// Search for this path in the existing requirements:
@@ -123,123 +230,106 @@ func patchParsedGoMod(goMod *modfile.File, update updater.Update) error {
 	}
 
 	// Search for this path in the replacements:
-	for _, rep := range goMod.Replace {
-		if rep.New.Path == update.Path {
-			if err := goMod.AddReplace(rep.Old.Path, rep.Old.Version, update.Path, update.Next); err != nil {
-				return fmt.Errorf("adding replacement: %w", err)
-			}
-			return nil
+	req, rep := activeReplacement(goMod, update.Path)
+	if rep == nil {
+		return nil
+	}
+	if rep.Old.Version == "" || req.Mod.Version != rep.New.Version {
+		if err := goMod.AddReplace(rep.Old.Path, rep.Old.Version, update.Path, update.Next); err != nil {
+			return fmt.Errorf("adding replacement: %w", err)
 		}
+		return nil
 	}
 
+	// The requirement and a version-specific replacement move in lockstep with the replacement, e.g.
+	// foo v1.2.0 => fork v1.2.0 becomes foo v1.3.0 => fork v1.3.0, so the replacement still applies:
+	oldPath := rep.Old.Path
+	if err := goMod.DropReplace(rep.Old.Path, rep.Old.Version); err != nil {
+		return fmt.Errorf("dropping replacement: %w", err)
+	}
+	if err := goMod.AddReplace(oldPath, update.Next, update.Path, update.Next); err != nil {
+		return fmt.Errorf("adding replacement: %w", err)
+	}
+	if err := goMod.AddRequire(oldPath, update.Next); err != nil {
+		return fmt.Errorf("adding requirement: %w", err)
+	}
 	return nil
 }
 
-func (u *Updater) updateSourceCode(up updater.Update) error {
-	// replace foo.bar/v1 with foo.bar/v2 in imports:
-	pattern, err := regexp.Compile(regexp.QuoteMeta(up.Path))
-	if err != nil {
-		return err
-	}
-
-	pkgNext := pathMajorVersion(up.Path, semver.Major(up.Next))
-	return filepath.Walk(u.root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			logrus.WithError(err).WithField("path", path).Warn("error accessing path")
-			return err
-		}
-		if info.IsDir() {
-			return nil
+// patchMajorRequirement moves a requirement to the module path of the next major version.
+// If the path replaces another module, the replaced module and the replacement move together.
+func patchMajorRequirement(goMod *modfile.File, update updater.Update) error {
+	major := semver.Major(update.Next)
+	requirePath := update.Path
+	if _, rep := activeReplacement(goMod, update.Path); rep != nil {
+		// e.g. foo/bar/v2 => fork/bar/v2 v2.1.0 becomes foo/bar/v3 => fork/bar/v3 v3.0.0
+		requirePath = rep.Old.Path
+		var oldVersion string
+		if rep.Old.Version != "" {
+			oldVersion = update.Next
 		}
-		if filepath.Ext(path) != ".go" {
-			return nil
+		if err := goMod.DropReplace(rep.Old.Path, rep.Old.Version); err != nil {
+			return fmt.Errorf("dropping major replacement: %w", err)
 		}
-		if err := updateSourceFile(path, pattern, pkgNext); err != nil {
-			return err
+		if err := goMod.AddReplace(pathMajorVersion(requirePath, major), oldVersion, pathMajorVersion(update.Path, major), update.Next); err != nil {
+			return fmt.Errorf("adding major replacement: %w", err)
 		}
-		return nil
-	})
-}
-
-func updateSourceFile(srcFile string, pattern *regexp.Regexp, replace string) error {
-	f, err := os.OpenFile(srcFile, os.O_RDWR, 0644)
-	if err != nil {
-		return fmt.Errorf("reading source code file: %w", err)
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			logrus.WithField("file_path", srcFile).WithError(err).Warn("closing source code file")
-		}
-	}()
 
-	var changed bool
-	var importing bool
-	in := bufio.NewScanner(f)
-	var buf bytes.Buffer
-	for in.Scan() {
-		line := in.Text()
-		if line == "import (" {
-			importing = true
-		} else if line == ")" && importing {
-			importing = false
-		}
-
-		if importing || strings.HasPrefix(line, "import") {
-			replaced := pattern.ReplaceAllString(line, replace)
-			changed = changed || replaced != line
-			line = replaced
-		}
-
-		_, _ = fmt.Fprintln(&buf, line)
-	}
-	if err := in.Err(); err != nil {
+	if err := patchMajorTools(goMod, requirePath, pathMajorVersion(requirePath, major)); err != nil {
 		return err
 	}
-
-	if !changed {
-		return nil
+	if err := goMod.DropRequire(requirePath); err != nil {
+		return fmt.Errorf("dropping major requirement: %w", err)
 	}
-	logrus.WithField("file_path", srcFile).Debug("updating go file")
-
-	if _, err := f.Seek(0, 0); err != nil {
-		return fmt.Errorf("resetting file offset: %w", err)
-	}
-	if _, err := f.Write(buf.Bytes()); err != nil {
-		return fmt.Errorf("writing updated source: %w", err)
+	if err := goMod.AddRequire(pathMajorVersion(requirePath, major), update.Next); err != nil {
+		return fmt.Errorf("adding major requirement: %w", err)
 	}
 	return nil
 }
 
-var fakeMainFile = []byte(`package main
-
-func main() {}
-`)
-
-func ensureGoFileInPath(path string) (func() error, error) {
-	fileInfos, err := ioutil.ReadDir(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading root dir: %w", err)
+// patchMajorTools moves `tool` directives for packages of a module to the module path of the next major version.
+func patchMajorTools(goMod *modfile.File, oldPath, newPath string) error {
+	var tools []string
+	for _, tool := range goMod.Tool {
+		tools = append(tools, tool.Path)
 	}
-	for _, fi := range fileInfos {
-		if filepath.Ext(fi.Name()) == ".go" {
-			return nil, nil
+	for _, tool := range tools {
+		pkg, ok := rewriteImportPath(tool, oldPath, newPath)
+		if !ok {
+			continue
+		}
+		if err := goMod.DropTool(tool); err != nil {
+			return fmt.Errorf("dropping major tool: %w", err)
+		}
+		if err := goMod.AddTool(pkg); err != nil {
+			return fmt.Errorf("adding major tool: %w", err)
 		}
 	}
+	return nil
+}
 
-	// There is no .go file in the root, but having one makes `go get` easier.
-	fakeMain := filepath.Join(path, "main.go")
-	if err := ioutil.WriteFile(fakeMain, fakeMainFile, 0600); err != nil {
-		return nil, fmt.Errorf("writing fake go file: %w", err)
-	}
-	return func() error {
-		return os.Remove(fakeMain)
-	}, nil
+// updatedRequirements returns the requirements of a go.mod file that were moved by an update, as `go get` arguments.
+func updatedRequirements(goMod *modfile.File, update updater.Update) []string {
+	path := updateModulePath(update)
+	var ret []string
+	for _, req := range goMod.Require {
+		rep := findReplacement(req.Mod.Path, req.Mod.Version, goMod.Replace)
+		if req.Mod.Path == path || (rep != nil && rep.New.Path == path) {
+			ret = append(ret, fmt.Sprintf("%s@%s", req.Mod.Path, req.Mod.Version))
+		}
+	}
+	return ret
 }
 
-func (u *Updater) updateGoSum(ctx context.Context, path string) error {
-	// Shell out to the Go SDK for this, so the user has more control over generation:
-	if err := cmd.CommandExecute(ctx, path, "go", "get", "-d", "-v"); err != nil {
-		return fmt.Errorf("updating go.sum: %w", err)
+func (u *Updater) updateGoSum(ctx context.Context, path string, requirements []string) error {
+	// Shell out to the Go SDK for this, so the user has more control over generation.
+	// Requirements are named explicitly, as the module root may not be a package:
+	if len(requirements) > 0 {
+		args := append([]string{"get", "-d", "-v"}, requirements...)
+		if err := cmd.CommandExecute(ctx, path, "go", args...); err != nil {
+			return fmt.Errorf("updating go.sum: %w", err)
+		}
 	}
 
 	if u.Tidy {
@@ -1,15 +1,20 @@
 package gomodules_test
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/thepwagner/action-update-go/gomodules"
+	"github.com/thepwagner/action-update/repo"
 	"github.com/thepwagner/action-update/updater"
 	"github.com/thepwagner/action-update/updatertest"
 )
@@ -83,6 +88,150 @@ func TestUpdater_ApplyUpdate_Major(t *testing.T) {
 	assert.Contains(t, mainGo, "github.com/caarlos0/env/v6")
 }
 
+func TestUpdater_ApplyUpdate_Major_Imports(t *testing.T) {
+	env6 := updater.Update{
+		Path:     "github.com/caarlos0/env/v5",
+		Previous: "v5.1.4",
+		Next:     "v6.2.0",
+	}
+	tempDir := updatertest.ApplyUpdateToFixture(t, "majorimports", updaterFactory(gomodules.WithMajorVersions(true)), env6)
+	readSource := func(fn string) string {
+		b, err := ioutil.ReadFile(filepath.Join(tempDir, fn))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	// Named imports and trailing comments are preserved:
+	mainGo := readSource("main.go")
+	assert.Contains(t, mainGo, `environment "github.com/caarlos0/env/v6" // configuration`)
+
+	// Single-line imports are updated, comments are not:
+	singleGo := readSource("single.go")
+	assert.Contains(t, singleGo, `import "github.com/caarlos0/env/v6" // parse without options`)
+	assert.Contains(t, singleGo, `// Docs reference github.com/caarlos0/env/v5 in comments.`)
+
+	// cgo preamble is untouched:
+	cgoGo := readSource("cgo.go")
+	assert.Contains(t, cgoGo, `// import "github.com/caarlos0/env/v5"`)
+	assert.Contains(t, cgoGo, `"github.com/caarlos0/env/v6"`)
+
+	// Paths that share a prefix are not updated:
+	lookalikeGo := readSource("lookalike.go")
+	assert.Contains(t, lookalikeGo, `"github.com/caarlos0/env/v50"`)
+}
+
+func TestUpdater_ApplyUpdate_Major_Scoped(t *testing.T) {
+	env6 := updater.Update{
+		Path:     "github.com/caarlos0/env/v5",
+		Previous: "v5.1.4",
+		Next:     "v6.2.0",
+	}
+	cases := map[string]struct {
+		opts      []gomodules.UpdaterOpt
+		updated   []string
+		unchanged []string
+	}{
+		"default": {
+			updated:   []string{"main.go", "nested/nested.go"},
+			unchanged: []string{"testdata/fixture.go"},
+		},
+		"nested module excluded": {
+			opts:      []gomodules.UpdaterOpt{gomodules.WithExclude("nested")},
+			updated:   []string{"main.go"},
+			unchanged: []string{"nested/nested.go", "testdata/fixture.go"},
+		},
+		"rewrite testdata": {
+			opts:    []gomodules.UpdaterOpt{gomodules.WithRewriteTestdata(true)},
+			updated: []string{"main.go", "nested/nested.go", "testdata/fixture.go"},
+		},
+	}
+
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			opts := append([]gomodules.UpdaterOpt{gomodules.WithMajorVersions(true)}, c.opts...)
+			tempDir := updatertest.ApplyUpdateToFixture(t, "majorscoped", updaterFactory(opts...), env6)
+			for _, fn := range c.updated {
+				b, err := ioutil.ReadFile(filepath.Join(tempDir, fn))
+				require.NoError(t, err)
+				assert.Contains(t, string(b), "github.com/caarlos0/env/v6", fn)
+			}
+			for _, fn := range c.unchanged {
+				b, err := ioutil.ReadFile(filepath.Join(tempDir, fn))
+				require.NoError(t, err)
+				assert.Contains(t, string(b), "github.com/caarlos0/env/v5", fn)
+			}
+		})
+	}
+}
+
+func TestUpdater_ApplyUpdate_Major_References(t *testing.T) {
+	env6 := updater.Update{
+		Path:     "github.com/caarlos0/env/v5",
+		Previous: "v5.1.4",
+		Next:     "v6.2.0",
+	}
+	readFiles := func(tempDir string) (mainGo, configProto, readme string) {
+		for fn, s := range map[string]*string{"main.go": &mainGo, "config.proto": &configProto, "README.md": &readme} {
+			b, err := ioutil.ReadFile(filepath.Join(tempDir, fn))
+			require.NoError(t, err)
+			*s = string(b)
+		}
+		return
+	}
+
+	t.Run("imports only", func(t *testing.T) {
+		tempDir := updatertest.ApplyUpdateToFixture(t, "majorreferences", updaterFactory(gomodules.WithMajorVersions(true)), env6)
+		mainGo, configProto, readme := readFiles(tempDir)
+		assert.Contains(t, mainGo, `"github.com/caarlos0/env/v6"`)
+		assert.Contains(t, mainGo, "//go:generate go run github.com/caarlos0/env/v5/cmd/envgen@v5.1.4")
+		assert.Contains(t, mainGo, `"https://pkg.go.dev/github.com/caarlos0/env/v5"`)
+		assert.Contains(t, configProto, `option go_package = "github.com/caarlos0/env/v5/config";`)
+		assert.Contains(t, readme, "go get github.com/caarlos0/env/v5@v5.1.4")
+	})
+
+	t.Run("all references", func(t *testing.T) {
+		hook := &logHook{}
+		logrus.AddHook(hook)
+		defer logrus.StandardLogger().ReplaceHooks(logrus.LevelHooks{})
+
+		factory := updaterFactory(gomodules.WithMajorVersions(true), gomodules.WithRewriteReferences(
+			gomodules.ReferenceDirectives, gomodules.ReferenceStrings, gomodules.ReferenceProto, gomodules.ReferenceDocs))
+		tempDir := updatertest.ApplyUpdateToFixture(t, "majorreferences", factory, env6)
+		mainGo, configProto, readme := readFiles(tempDir)
+		assert.Contains(t, mainGo, `"github.com/caarlos0/env/v6"`)
+		assert.Contains(t, mainGo, "//go:generate go run github.com/caarlos0/env/v6/cmd/envgen@v6.2.0 -type cfg")
+		assert.Contains(t, mainGo, `"https://pkg.go.dev/github.com/caarlos0/env/v6"`)
+
+		// Only go_package options are updated in .proto files:
+		assert.Contains(t, configProto, `option go_package = "github.com/caarlos0/env/v6/config";`)
+		assert.Contains(t, configProto, "// Generated code is imported from github.com/caarlos0/env/v5/config.")
+
+		assert.Contains(t, readme, "go get github.com/caarlos0/env/v6@v6.2.0")
+		assert.Contains(t, readme, "Not to be confused with github.com/caarlos0/env/v50.")
+
+		// Changed non-go files are reported together:
+		var reported []interface{}
+		for _, e := range hook.entries {
+			if e.Message == "updated references in non-go files" {
+				reported = append(reported, e.Data["files"])
+			}
+		}
+		assert.Equal(t, []interface{}{[]string{"README.md", "config.proto"}}, reported)
+	})
+}
+
+// logHook records log entries.
+type logHook struct {
+	entries []logrus.Entry
+}
+
+func (h *logHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *logHook) Fire(e *logrus.Entry) error {
+	h.entries = append(h.entries, *e)
+	return nil
+}
+
 func TestUpdater_ApplyUpdate_Major_Gopkg(t *testing.T) {
 	yaml1 := updater.Update{
 		Path:     "gopkg.in/yaml.v1",
@@ -106,6 +255,27 @@ func TestUpdater_ApplyUpdate_Major_Gopkg(t *testing.T) {
 	assert.Contains(t, mainGo, "gopkg.in/yaml.v2")
 }
 
+func TestUpdater_ApplyUpdate_Major_Unsuffixed(t *testing.T) {
+	cli2 := updater.Update{
+		Path:     "github.com/urfave/cli",
+		Previous: "v1.22.5",
+		Next:     "v2.3.0",
+	}
+	tempDir := updatertest.ApplyUpdateToFixture(t, "unsuffixed", updaterFactory(gomodules.WithMajorVersions(true)), cli2)
+	uf := readModFiles(t, tempDir)
+
+	// Path gains a major version suffix in module files:
+	assert.NotContains(t, uf.GoMod, "github.com/urfave/cli v1.22.5")
+	assert.Contains(t, uf.GoMod, "github.com/urfave/cli/v2 v2.3.0")
+
+	// Path is updated in source code:
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, "main.go"))
+	require.NoError(t, err)
+	mainGo := string(b)
+	assert.NotContains(t, mainGo, `"github.com/urfave/cli"`)
+	assert.Contains(t, mainGo, `"github.com/urfave/cli/v2"`)
+}
+
 func TestUpdater_ApplyUpdate_NotInRoot(t *testing.T) {
 	tempDir := updatertest.ApplyUpdateToFixture(t, "notinroot", updaterFactory(), pkgErrors081)
 	uf := readModFiles(t, tempDir)
@@ -154,6 +324,213 @@ func TestUpdater_ApplyUpdate_Replace(t *testing.T) {
 	assert.Contains(t, uf.GoSum, "github.com/thepwagner/errors v0.8.1")
 }
 
+func TestUpdater_ApplyUpdate_Major_Replace(t *testing.T) {
+	env7 := updater.Update{
+		Path:     "github.com/caarlos0/env/v6",
+		Previous: "v6.2.0",
+		Next:     "v7.1.0",
+	}
+	tempDir := updatertest.ApplyUpdateToFixture(t, "majorreplace", updaterFactory(gomodules.WithMajorVersions(true)), env7)
+	uf := readModFiles(t, tempDir)
+
+	// Both the requirement and the replacement move to the next major:
+	assert.NotContains(t, uf.GoMod, "example.com/env/v6")
+	assert.Contains(t, uf.GoMod, "example.com/env/v7 v7.1.0")
+	assert.Contains(t, uf.GoMod, "example.com/env/v7 => github.com/caarlos0/env/v7 v7.1.0")
+
+	// Source code references the replaced path:
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"example.com/env/v7"`)
+}
+
+func TestUpdater_ApplyUpdate_Major_ReplaceMissing(t *testing.T) {
+	tempDir := updatertest.TempDirFromFixture(t, "majorreplace")
+	u := gomodules.NewUpdater(tempDir, gomodules.WithMajorVersions(true))
+
+	err := u.ApplyUpdate(context.Background(), updater.Update{
+		Path:     "github.com/caarlos0/env/v6",
+		Previous: "v6.2.0",
+		Next:     "v99.0.0",
+	})
+	assert.EqualError(t, err, "replacement github.com/caarlos0/env/v6 has no v99 major version at v99.0.0")
+
+	// Nothing is written:
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"example.com/env/v6"`)
+}
+
+func TestUpdater_ApplyUpdate_Exclude(t *testing.T) {
+	pkgErrors090 := updater.Update{
+		Path:     "github.com/pkg/errors",
+		Previous: "v0.8.0",
+		Next:     "v0.9.0",
+	}
+	readNested := func(tempDir string) string {
+		b, err := ioutil.ReadFile(filepath.Join(tempDir, "nested", "go.mod"))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	tempDir := updatertest.ApplyUpdateToFixture(t, "exclude", updaterFactory(), pkgErrors090)
+	nested := readNested(tempDir)
+	assert.Contains(t, nested, "github.com/pkg/errors v0.9.0")
+	assert.Contains(t, nested, "github.com/pkg/errors v0.7.0")
+	assert.Contains(t, nested, "github.com/pkg/errors v0.9.1")
+
+	tempDir = updatertest.ApplyUpdateToFixture(t, "exclude", updaterFactory(gomodules.WithDropStaleExcludes(true)), pkgErrors090)
+	nested = readNested(tempDir)
+	assert.Contains(t, nested, "github.com/pkg/errors v0.9.0")
+	assert.NotContains(t, nested, "github.com/pkg/errors v0.7.0")
+	assert.Contains(t, nested, "github.com/pkg/errors v0.9.1")
+}
+
+func TestUpdater_ApplyUpdate_ReplaceVersion(t *testing.T) {
+	replacement := updater.Update{
+		Path:     "github.com/thepwagner/errors",
+		Previous: "v0.8.0",
+		Next:     "v0.8.1",
+	}
+	tempDir := updatertest.ApplyUpdateToFixture(t, "replaceversion", updaterFactory(), replacement)
+	uf := readModFiles(t, tempDir)
+
+	// Requirement and version-specific replacement move with the replacement:
+	assert.Contains(t, uf.GoMod, "require github.com/pkg/errors v0.8.1")
+	assert.Contains(t, uf.GoMod, "replace github.com/pkg/errors v0.8.1 => github.com/thepwagner/errors v0.8.1")
+	assert.NotContains(t, uf.GoMod, "v0.8.0")
+
+	// The nested module's replacement doesn't apply to its requirement, so it's unchanged:
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, "nested", "go.mod"))
+	require.NoError(t, err)
+	nested := string(b)
+	assert.Contains(t, nested, "require github.com/pkg/errors v0.8.0")
+	assert.Contains(t, nested, "replace github.com/pkg/errors v0.7.0 => github.com/thepwagner/errors v0.8.0")
+}
+
+func TestUpdater_ApplyUpdate_DropFork(t *testing.T) {
+	ctx := context.Background()
+	pinned, unmerged := releaseFixture(t)
+	promo101 := updater.Update{
+		Path:     "example.com/promo",
+		Previous: "v1.0.0",
+		Next:     "v1.0.1",
+	}
+
+	for label, fork := range map[string]string{"released": pinned, "unmerged": unmerged} {
+		fork := fork
+		t.Run(label, func(t *testing.T) {
+			tempDir := forkFixture(t, fork)
+			u := gomodules.NewUpdater(tempDir, gomodules.WithTrackForks(true), gomodules.WithTidy(false))
+			update, err := u.Check(ctx, updater.Dependency{Path: "example.com/fork", Version: fork}, nil)
+			require.NoError(t, err)
+			require.Equal(t, &promo101, update)
+			require.NoError(t, u.ApplyUpdate(ctx, *update))
+
+			uf := readModFiles(t, tempDir)
+			assert.Contains(t, uf.GoMod, "require example.com/promo v1.0.1")
+			assert.NotContains(t, uf.GoMod, "replace")
+			assert.NotContains(t, uf.GoMod, "example.com/fork")
+		})
+	}
+
+	t.Run("ordinary update", func(t *testing.T) {
+		// Another module requires example.com/promo, and its update was not proposed for the fork:
+		tempDir := forkFixture(t, pinned)
+		writeFixtureFile(t, tempDir, "direct/go.mod", "module example.com/direct\n\ngo 1.15\n\nrequire example.com/promo v1.0.0\n")
+		u := gomodules.NewUpdater(tempDir, gomodules.WithTrackForks(true), gomodules.WithTidy(false))
+		require.NoError(t, u.ApplyUpdate(ctx, promo101))
+
+		b, err := ioutil.ReadFile(filepath.Join(tempDir, gomodules.GoModFn))
+		require.NoError(t, err)
+		assert.Contains(t, string(b), "replace example.com/promo => example.com/fork "+pinned)
+		b, err = ioutil.ReadFile(filepath.Join(tempDir, "direct", gomodules.GoModFn))
+		require.NoError(t, err)
+		assert.Contains(t, string(b), "require example.com/promo v1.0.1")
+	})
+
+	t.Run("ignore forks", func(t *testing.T) {
+		tempDir := forkFixture(t, pinned)
+		u := gomodules.NewUpdater(tempDir, gomodules.WithTidy(false))
+		require.NoError(t, u.ApplyUpdate(ctx, promo101))
+
+		b, err := ioutil.ReadFile(filepath.Join(tempDir, gomodules.GoModFn))
+		require.NoError(t, err)
+		assert.Contains(t, string(b), "replace example.com/promo => example.com/fork "+pinned)
+	})
+
+	t.Run("pull request", func(t *testing.T) {
+		assert.Equal(t, "action-update-go/main/example.com/promo/v1.0.1", updater.DefaultUpdateBranchNamer{}.Format("main", promo101))
+
+		title, body, err := repo.NewGitHubPullRequestContent(nil, []byte("key")).Generate(context.Background(), updater.NewUpdateGroup("", promo101))
+		require.NoError(t, err)
+		assert.Equal(t, "Update example.com/promo from v1.0.0 to v1.0.1", title)
+		assert.True(t, strings.HasPrefix(body, "Here is example.com/promo v1.0.1, I hope it works.\n"), body)
+	})
+}
+
+func TestUpdater_ApplyUpdate_SiblingModules(t *testing.T) {
+	common120 := updater.Update{
+		Path:     "github.com/thepwagner/action-update-go/siblings/common",
+		Previous: "v1.0.0",
+		Next:     "v1.2.0",
+	}
+
+	// The tagged version is resolved from a module proxy once the local replacement is dropped:
+	localProxy(t)
+
+	assertDropped := func(t *testing.T, tempDir string, dropped bool) {
+		b, err := ioutil.ReadFile(filepath.Join(tempDir, gomodules.GoModFn))
+		require.NoError(t, err)
+		goMod := string(b)
+		assert.Contains(t, goMod, "require github.com/thepwagner/action-update-go/siblings/common v1.2.0")
+		if dropped {
+			assert.NotContains(t, goMod, "replace")
+		} else {
+			assert.Contains(t, goMod, "replace github.com/thepwagner/action-update-go/siblings/common => ./common")
+		}
+	}
+	newUpdater := func(tempDir string) *gomodules.Updater {
+		return gomodules.NewUpdater(tempDir, gomodules.WithSiblingModules(true), gomodules.WithReleaseBranches("release/*"), gomodules.WithTidy(false))
+	}
+
+	cases := map[string]struct {
+		branch  string
+		dropped bool
+	}{
+		"development branch": {branch: "main"},
+		"release branch":     {branch: "release/1.x", dropped: true},
+		// Without listing dependencies first, e.g. when a closed pull request is recreated:
+		"development update branch": {branch: "action-update-go/main/" + common120.Path + "/v1.2.0"},
+		"release update branch":     {branch: "action-update-go/release/1.x/" + common120.Path + "/v1.2.0", dropped: true},
+	}
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			tempDir, _ := gitFixture(t, "siblings", c.branch, siblingTags...)
+			require.NoError(t, newUpdater(tempDir).ApplyUpdate(context.Background(), common120))
+			assertDropped(t, tempDir, c.dropped)
+		})
+	}
+
+	t.Run("multiple base branches", func(t *testing.T) {
+		ctx := context.Background()
+		tempDir, git := gitFixture(t, "siblings", "main", siblingTags...)
+		git("branch", "release/1.x")
+		u := newUpdater(tempDir)
+
+		// The same updater visits each base branch, and applies updates to a branch named after it:
+		for _, base := range []string{"main", "release/1.x"} {
+			git("checkout", "-q", base)
+			_, err := u.Dependencies(ctx)
+			require.NoError(t, err)
+			git("checkout", "-q", "-b", updater.DefaultUpdateBranchNamer{}.Format(base, common120))
+			require.NoError(t, u.ApplyUpdate(ctx, common120))
+			assertDropped(t, tempDir, base != "main")
+			git("reset", "-q", "--hard")
+		}
+	})
+}
+
 func TestUpdater_ApplyUpdate_MultimoduleCommon(t *testing.T) {
 	logrus160 := updater.Update{
 		Path: "github.com/sirupsen/logrus",
@@ -178,14 +555,272 @@ func TestUpdater_ApplyUpdate_MultimoduleCmd(t *testing.T) {
 }
 
 func TestUpdater_ApplyUpdate_Multimodule(t *testing.T) {
-	tempDir := updatertest.ApplyUpdateToFixture(t, "multimodule", updaterFactory(), pkgErrors081)
-	uf := readModFiles(t, filepath.Join(tempDir, "cmd"))
+	logrus160 := updater.Update{
+		Path: "github.com/sirupsen/logrus",
+		Next: "v1.6.0",
+	}
+	cases := map[string]struct {
+		update    updater.Update
+		previous  string
+		untouched string
+	}{
+		"cmd":    {update: pkgErrors081, previous: "v0.8.0", untouched: "common"},
+		"common": {update: logrus160, previous: "v1.5.0", untouched: "cmd"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tempDir := updatertest.ApplyUpdateToFixture(t, "multimodule", updaterFactory(), tc.update)
+			uf := readModFiles(t, filepath.Join(tempDir, name))
+			for _, s := range uf.GoModFiles() {
+				assert.NotContains(t, s, fmt.Sprintf("%s %s", tc.update.Path, tc.previous))
+				assert.Contains(t, s, fmt.Sprintf("%s %s", tc.update.Path, tc.update.Next))
+			}
+
+			// Modules that don't require the path are untouched:
+			untouched := readModFiles(t, filepath.Join(tempDir, tc.untouched))
+			fixture := readModFiles(t, filepath.Join("testdata", "multimodule", tc.untouched))
+			assert.Equal(t, fixture, untouched)
+		})
+	}
+}
+
+func TestUpdater_ApplyUpdate_Nested(t *testing.T) {
+	tempDir := updatertest.ApplyUpdateToFixture(t, "nested", updaterFactory(), pkgErrors081)
+	uf := readModFiles(t, filepath.Join(tempDir, "services", "api"))
 	for _, s := range uf.GoModFiles() {
 		assert.NotContains(t, s, "github.com/pkg/errors v0.8.0")
 		assert.Contains(t, s, "github.com/pkg/errors v0.8.1")
 	}
 }
 
+func TestUpdater_ApplyUpdate_Workspace(t *testing.T) {
+	tempDir := updatertest.ApplyUpdateToFixture(t, "workspace", updaterFactory(), pkgErrors081)
+	for _, mod := range []string{"api", "worker"} {
+		uf := readModFiles(t, filepath.Join(tempDir, mod))
+		for _, s := range uf.GoModFiles() {
+			assert.NotContains(t, s, "github.com/pkg/errors v0.8.0")
+			assert.Contains(t, s, "github.com/pkg/errors v0.8.1")
+		}
+	}
+
+	// Modules outside the workspace are not updated:
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, "legacy", gomodules.GoModFn))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "github.com/stretchr/testify v1.6.1")
+
+	// Without a replacement to update, go.work is untouched:
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "workspace", gomodules.GoWorkFn))
+	require.NoError(t, err)
+	b, err = ioutil.ReadFile(filepath.Join(tempDir, gomodules.GoWorkFn))
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(b))
+
+	// go.work.sum is regenerated, dropping stale checksums but keeping those of the workspace replacement:
+	b, err = ioutil.ReadFile(filepath.Join(tempDir, gomodules.GoWorkSumFn))
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "github.com/pkg/errors v0.8.0")
+	assert.Contains(t, string(b), "github.com/sirupsen/logrus v1.6.0 h1:UBcNElsrwanuuMsnGSlYmtmgbb23qDR5dG+6X6Oo89I=")
+}
+
+func TestUpdater_ApplyUpdate_WorkspaceReplace(t *testing.T) {
+	logrus170 := updater.Update{
+		Path:     "github.com/sirupsen/logrus",
+		Previous: "v1.6.0",
+		Next:     "v1.7.0",
+	}
+	tempDir := updatertest.ApplyUpdateToFixture(t, "workspace", updaterFactory(), logrus170)
+
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, gomodules.GoWorkFn))
+	require.NoError(t, err)
+	goWork := string(b)
+	assert.NotContains(t, goWork, "github.com/sirupsen/logrus v1.6.0")
+	assert.Contains(t, goWork, "github.com/sirupsen/logrus v1.7.0")
+
+	// go.work.sum is regenerated without the stale checksums, which the worker's go.sum now covers:
+	_, err = os.Stat(filepath.Join(tempDir, gomodules.GoWorkSumFn))
+	assert.True(t, os.IsNotExist(err))
+	b, err = ioutil.ReadFile(filepath.Join(tempDir, "worker", gomodules.GoSumFn))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "github.com/sirupsen/logrus v1.7.0 h1:ShrD1U9pZB12TX0cVy0DtePoCH97K8EtX+mg7ZARUtM=")
+	assert.NotContains(t, string(b), "github.com/sirupsen/logrus v1.6.0")
+}
+
+func TestUpdater_ApplyUpdate_Unaligned(t *testing.T) {
+	aligned := updater.Update{
+		Path:     "github.com/pkg/errors",
+		Previous: "v0.8.0",
+		Next:     "v0.8.1",
+	}
+	tempDir := updatertest.ApplyUpdateToFixture(t, "unaligned", updaterFactory(gomodules.WithAlignVersions(true)), aligned)
+
+	// Lagging module is updated:
+	uf := readModFiles(t, filepath.Join(tempDir, "a"))
+	assert.NotContains(t, uf.GoMod, "github.com/pkg/errors v0.8.0")
+	assert.Contains(t, uf.GoMod, "github.com/pkg/errors v0.8.1")
+
+	// Module at the target version is untouched:
+	b := readModFiles(t, filepath.Join(tempDir, "b"))
+	fixture := readModFiles(t, filepath.Join("testdata", "unaligned", "b"))
+	assert.Equal(t, fixture, b)
+}
+
+func TestUpdater_ApplyUpdate_IndirectBatchChanged(t *testing.T) {
+	tempDir := updatertest.TempDirFromFixture(t, "indirect")
+	u := gomodules.NewUpdater(tempDir, gomodules.WithIndirectPolicy(gomodules.IndirectBatch))
+
+	// A batch that no longer matches the available updates is not applied:
+	err := u.ApplyUpdate(context.Background(), updater.Update{Path: gomodules.IndirectBatchPath, Next: "000000000000"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "indirect dependency updates changed since check: expected 000000000000")
+	assert.Equal(t, readModFiles(t, filepath.Join("testdata", "indirect")), readModFiles(t, tempDir))
+}
+
+func TestUpdater_ApplyUpdate_Refresh(t *testing.T) {
+	cases := map[string]struct {
+		mode     gomodules.RefreshMode
+		strategy string
+		expected string
+	}{
+		"patch": {mode: gomodules.RefreshPatch, expected: "v1.0.1"},
+		"minor": {mode: gomodules.RefreshMinor, expected: "v1.1.0"},
+		// Strategies narrow the refresh of the modules they match:
+		"patch strategy": {mode: gomodules.RefreshMinor, strategy: "patch", expected: "v1.0.1"},
+	}
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			localProxy(t)
+			tempDir := updatertest.TempDirFromFixture(t, "refresh")
+			if c.strategy != "" {
+				writeFixtureFile(t, tempDir, gomodules.StrategyConfigFn, "strategies:\n  - pattern: example.com/refresh\n    strategy: "+c.strategy+"\n")
+			}
+			u := gomodules.NewUpdater(tempDir, gomodules.WithRefresh(c.mode))
+			refresh, err := u.Check(context.Background(), updater.Dependency{Path: gomodules.RefreshBatchPath}, nil)
+			require.NoError(t, err)
+			require.NotNil(t, refresh)
+			require.NoError(t, u.ApplyUpdate(context.Background(), *refresh))
+
+			uf := readModFiles(t, tempDir)
+			assert.Contains(t, uf.GoMod, "example.com/refresh "+c.expected)
+			assert.Contains(t, uf.GoSum, "example.com/refresh "+c.expected)
+		})
+	}
+}
+
+func TestUpdater_ApplyUpdate_RefreshChanged(t *testing.T) {
+	localProxy(t)
+	tempDir := updatertest.TempDirFromFixture(t, "refresh")
+	u := gomodules.NewUpdater(tempDir, gomodules.WithRefresh(gomodules.RefreshPatch))
+
+	// A refresh that no longer matches the available versions is not applied:
+	err := u.ApplyUpdate(context.Background(), updater.Update{Path: gomodules.RefreshBatchPath, Next: "000000000000"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "module graph refresh changed since check: expected 000000000000")
+	assert.Equal(t, readTree(t, filepath.Join("testdata", "refresh")), readTree(t, tempDir))
+}
+
+func TestUpdater_ApplyUpdate_GoVersion(t *testing.T) {
+	goVersion := updater.Update{Path: gomodules.GoVersionPath, Previous: "v1.20", Next: "v1.21.5"}
+	tempDir := updatertest.ApplyUpdateToFixture(t, "goversion", updaterFactory(gomodules.WithGoVersion(true)), goVersion)
+
+	files := map[string][]string{
+		// The go directive is only raised to the next minor version, keeping its precision:
+		gomodules.GoModFn:                          {"go 1.21\n", "toolchain go1.21.5\n"},
+		filepath.Join("nested", gomodules.GoModFn): {"go 1.21.0\n", "toolchain go1.21.5\n"},
+		"Dockerfile":                               {"FROM golang:1.21.5-alpine AS builder\n", "FROM alpine:3.18\n"},
+		filepath.Join(".github", "workflows", "ci.yaml"): {
+			"go-version: '1.21.5'\n",
+			"go-version: 1.21 # minimum\n",
+			"go-version: '1.20.x'\n",
+		},
+	}
+	for fn, expected := range files {
+		b, err := ioutil.ReadFile(filepath.Join(tempDir, fn))
+		require.NoError(t, err)
+		for _, s := range expected {
+			assert.Contains(t, string(b), s, fn)
+		}
+	}
+}
+
+func TestUpdater_ApplyUpdate_GoVersionBump(t *testing.T) {
+	localProxy(t)
+
+	// The fixture declares go 1.20, like v1.1.0:
+	cases := map[string]string{
+		"v1.2.0": "1.22",
+		"v1.1.0": "1.20",
+	}
+	for next, goVersion := range cases {
+		t.Run(next, func(t *testing.T) {
+			gover := updater.Update{Path: "example.com/gover", Previous: "v1.0.0", Next: next}
+			tempDir := updatertest.ApplyUpdateToFixture(t, "gorequirement", updaterFactory(gomodules.WithGoVersionPolicy(gomodules.GoVersionBump), gomodules.WithTidy(false)), gover)
+
+			goMod := readModFiles(t, tempDir).GoMod
+			assert.Contains(t, goMod, "go "+goVersion+"\n")
+			assert.Contains(t, goMod, "example.com/gover "+next)
+		})
+	}
+}
+
+func TestUpdater_ApplyUpdate_PinnedTools(t *testing.T) {
+	localProxy(t)
+	tool := updater.Update{Path: "example.com/tool", Previous: "v1.0.0", Next: "v1.1.0"}
+	tempDir := updatertest.ApplyUpdateToFixture(t, "pinned", updaterFactory(gomodules.WithPinnedTools(true)), tool)
+
+	files := map[string][]string{
+		"gen.go": {
+			"//go:generate go run example.com/tool/cmd/tool@v1.1.0 -out generated.go\n",
+			`const Usage = "go run example.com/tool/cmd/tool@v1.0.0"`,
+		},
+		"Makefile": {
+			"go install example.com/tool/cmd/tool@v1.1.0\n",
+			"go install example.com/missing/cmd/missing@v0.1.0\n",
+		},
+		filepath.Join("scripts", "lint.sh"): {"go run -mod=mod example.com/tool/cmd/tool@v1.1.0 ./...\n"},
+		filepath.Join(".github", "workflows", "ci.yaml"): {
+			"go install example.com/tool/cmd/tool@v1.1.0\n",
+			"go install example.com/tool/cmd/tool@latest\n",
+		},
+	}
+	for fn, expected := range files {
+		b, err := ioutil.ReadFile(filepath.Join(tempDir, fn))
+		require.NoError(t, err)
+		for _, s := range expected {
+			assert.Contains(t, string(b), s, fn)
+		}
+	}
+}
+
+func TestUpdater_ApplyUpdate_PinnedToolsResolvedOnce(t *testing.T) {
+	localProxy(t)
+	tempDir := updatertest.TempDirFromFixture(t, "pinned")
+	u := gomodules.NewUpdater(tempDir, gomodules.WithPinnedTools(true))
+	_, err := u.Dependencies(context.Background())
+	require.NoError(t, err)
+
+	// References resolved by Dependencies are not queried again:
+	t.Setenv("GOPROXY", "off")
+	require.NoError(t, u.ApplyUpdate(context.Background(), updater.Update{Path: "example.com/tool", Previous: "v1.0.0", Next: "v1.1.0"}))
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, "Makefile"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "go install example.com/tool/cmd/tool@v1.1.0\n")
+}
+
+func TestUpdater_ApplyUpdate_VerifyTools(t *testing.T) {
+	localProxy(t)
+	tool := updater.Update{Path: "example.com/tool", Previous: "v1.0.0", Next: "v1.1.0"}
+
+	for _, fixture := range []string{"tools", "toolsgo"} {
+		t.Run(fixture, func(t *testing.T) {
+			tempDir := updatertest.ApplyUpdateToFixture(t, fixture, updaterFactory(gomodules.WithVerifyTools(true)), tool)
+			goMod := readModFiles(t, tempDir).GoMod
+			assert.Contains(t, goMod, "example.com/tool v1.1.0")
+			assert.NoFileExists(t, filepath.Join(tempDir, "main.go"))
+		})
+	}
+}
+
 type modFiles struct {
 	GoMod, GoSum string
 	ModulesTxt   string
@@ -1,15 +1,12 @@
 package gomodules
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -23,18 +20,80 @@ import (
 func (u *Updater) Check(ctx context.Context, dep updater.Dependency, filter func(string) bool) (*updater.Update, error) {
 	log := logrus.WithField("path", dep.Path)
 
+	if dep.Path == IndirectBatchPath && u.IndirectPolicy == IndirectBatch {
+		return u.checkIndirectBatch(ctx, dep)
+	}
+	if dep.Path == RefreshBatchPath && u.Refresh != "" {
+		return u.checkRefresh(ctx, dep)
+	}
+
+	excluded, err := u.excludedVersions(dep.Path, dep.Path)
+	if err != nil {
+		return nil, fmt.Errorf("collecting excluded versions: %w", err)
+	}
+	filter = excludeFilter(filter, excluded)
+
+	strategy, err := u.strategy(dep.Path)
+	if err != nil {
+		return nil, err
+	}
+	if strategy == StrategyPinned {
+		log.Debug("skipping pinned module")
+		return nil, nil
+	}
+	filter = strategyFilter(filter, strategy, dep.Version)
+	// Strategies can only narrow the proposed updates: `latest` still requires MajorVersions for major updates.
+	majorVersions := u.MajorVersions
+	if strategy != "" && strategy != StrategyLatest {
+		majorVersions = false
+	}
+
+	if dep.Path == GoVersionPath && u.GoVersion {
+		return u.checkGoVersion(ctx, dep, filter)
+	}
+
+	if u.SiblingModules {
+		if dir, err := u.siblingModuleDir(dep.Path); err != nil {
+			return nil, fmt.Errorf("collecting sibling modules: %w", err)
+		} else if dir != "" {
+			return u.checkSiblingModule(ctx, dep, dir, filter)
+		}
+	}
+
+	if u.TrackForks {
+		drop, err := u.checkForkReplacement(ctx, dep, filter)
+		if err != nil {
+			return nil, fmt.Errorf("checking fork: %w", err)
+		}
+		if drop != nil {
+			return drop, nil
+		}
+	}
+
 	if modfetch.IsPseudoVersion(dep.Version) {
+		if u.PromotePseudoVersions {
+			release, err := u.checkForPseudoVersionRelease(ctx, dep, filter)
+			if err != nil {
+				return nil, fmt.Errorf("checking for release: %w", err)
+			}
+			if release != nil {
+				return release, nil
+			}
+		}
+		if u.PseudoVersions {
+			return u.checkForPseudoVersionUpdate(ctx, dep, filter)
+		}
 		log.WithField("version", dep.Version).Debug("skipping pseudoversion module")
 		return nil, nil
 	}
 
-	if u.MajorVersions {
+	if majorVersions {
 		latest, err := u.checkForMajorUpdate(ctx, dep, filter)
 		if err != nil {
 			return nil, fmt.Errorf("checking for major update: %w", err)
 		}
 		if latest != nil {
-			return latest, nil
+			return u.checkGoRequirement(ctx, dep, latest, filter)
 		}
 	}
 
@@ -42,63 +101,148 @@ func (u *Updater) Check(ctx context.Context, dep updater.Dependency, filter func
 	if err != nil {
 		return nil, fmt.Errorf("checking for update: %w", err)
 	}
-	return latest, nil
+
+	if u.AlignVersions {
+		aligned, err := u.checkAlignment(dep, latest, filter)
+		if err != nil {
+			return nil, fmt.Errorf("checking alignment: %w", err)
+		}
+		return u.checkGoRequirement(ctx, dep, aligned, filter)
+	}
+	return u.checkGoRequirement(ctx, dep, latest, filter)
 }
 
-func (u *Updater) checkForMajorUpdate(ctx context.Context, dep updater.Dependency, filter func(string) bool) (*updater.Update, error) {
-	// Does this look like a versioned path?
-	nextMajorPath := pathNextMajorVersion(dep.Path)
-	if nextMajorPath == "" {
+// checkAlignment brings every go.mod file requiring a path to the same version.
+// This is the latest available version, or the newest version already required within the repository.
+func (u *Updater) checkAlignment(dep updater.Dependency, latest *updater.Update, filter func(string) bool) (*updater.Update, error) {
+	versions, err := u.requiredVersions(dep.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	target := latest
+	for _, v := range versions {
+		if semver.Compare(dep.Version, v) >= 0 || (filter != nil && !filter(v)) {
+			continue
+		}
+		if target == nil || semver.Compare(target.Next, v) < 0 {
+			target = &updater.Update{
+				Path:     dep.Path,
+				Previous: dep.Version,
+				Next:     v,
+			}
+		}
+	}
+	if target == nil {
 		return nil, nil
 	}
 
+	var lagging []string
+	for gomod, v := range versions {
+		if semver.Compare(v, target.Next) < 0 {
+			rel, _ := filepath.Rel(u.root, gomod)
+			lagging = append(lagging, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(lagging)
+	logrus.WithFields(logrus.Fields{
+		"path":    dep.Path,
+		"next":    target.Next,
+		"lagging": lagging,
+	}).Info("aligning module versions")
+	return target, nil
+}
+
+// checkForMajorUpdate probes successive major versions of a path, proposing the newest that resolves.
+func (u *Updater) checkForMajorUpdate(ctx context.Context, dep updater.Dependency, filter func(string) bool) (*updater.Update, error) {
 	log := logrus.WithField("path", dep.Path)
 	log.Debug("querying latest major version")
 
-	latest, err := u.queryModuleVersions(ctx, nextMajorPath, filter)
-	if err != nil {
-		if strings.Contains(err.Error(), "exit status 1") {
-			// Assume we queried for a major version that doesn't exist
-			return nil, nil
+	var latestVersion string
+	path, version := dep.Path, dep.Version
+	for jump := 0; u.MaxMajorJump == 0 || jump < u.MaxMajorJump; jump++ {
+		// Does this look like a versioned path?
+		nextMajorPath := pathNextMajorVersion(path, version)
+		if nextMajorPath == "" {
+			break
 		}
-		return nil, err
-	} else if latest == nil {
-		return nil, nil
-	}
 
-	log = log.WithFields(logrus.Fields{
-		"latest_version":  latest.Version,
-		"current_version": dep.Version,
-	})
-	if modfetch.IsPseudoVersion(latest.Version) {
-		log.Debug("skipping major update to pseudoversion")
+		// Exclude directives name the module path of the major version:
+		excluded, err := u.excludedVersions(dep.Path, nextMajorPath)
+		if err != nil {
+			return nil, fmt.Errorf("collecting excluded versions: %w", err)
+		}
+		latest, err := u.queryModuleVersions(ctx, nextMajorPath, excludeFilter(filter, excluded))
+		if err != nil {
+			if strings.Contains(err.Error(), "exit status 1") {
+				// Assume we queried for a major version that doesn't exist
+				break
+			}
+			return nil, err
+		}
+		if latest != nil {
+			if v := latestModuleVersion(latest); modfetch.IsPseudoVersion(v) {
+				log.WithField("path", nextMajorPath).Debug("skipping major update to pseudoversion")
+			} else {
+				latestVersion = v
+			}
+		}
+		path, version = nextMajorPath, ""
+	}
+	if latestVersion == "" {
 		return nil, nil
 	}
 
-	log.Info("major upgrade available")
+	log.WithFields(logrus.Fields{
+		"latest_version":  latestVersion,
+		"current_version": dep.Version,
+	}).Info("major upgrade available")
 	return &updater.Update{
 		Path:     dep.Path,
 		Previous: dep.Version,
-		Next:     latest.Version,
+		Next:     latestVersion,
 	}, nil
 }
 
+// latestModuleVersion returns the newest version from a version query.
+func latestModuleVersion(nfo *modinfo.ModulePublic) string {
+	if versCount := len(nfo.Versions); versCount > 0 {
+		return nfo.Versions[versCount-1]
+	}
+	return nfo.Version
+}
+
 var pathMajorVersionRE = regexp.MustCompile(`([\\./])v(\d+)$`)
 
-func pathNextMajorVersion(path string) string {
+// pathNextMajorVersion returns the module path of the next major version, e.g. github.com/foo/bar/v2 -> github.com/foo/bar/v3.
+// Paths without a major version suffix are at v0 or v1, or an +incompatible version that may have adopted modules.
+func pathNextMajorVersion(path, version string) string {
 	m := pathMajorVersionRE.FindStringSubmatch(path)
 	if len(m) == 0 {
-		return ""
+		if strings.HasPrefix(path, "gopkg.in/") {
+			// gopkg.in paths always carry a major version
+			return ""
+		}
+		nextMajorVersion := int64(2)
+		if semver.Build(version) == "+incompatible" {
+			// e.g. github.com/foo/bar v3.1.0+incompatible -> github.com/foo/bar/v3
+			nextMajorVersion, _ = strconv.ParseInt(strings.TrimPrefix(semver.Major(version), "v"), 10, 32)
+		}
+		return fmt.Sprintf("%s/v%d", path, nextMajorVersion)
 	}
 	currentMajorVersion, _ := strconv.ParseInt(m[2], 10, 32)
 	sep := m[1]
 	return fmt.Sprintf("%s%sv%d", path[:strings.LastIndex(path, sep)], sep, currentMajorVersion+1)
 }
 
+// pathMajorVersion returns the module path of basePath at a major version.
 func pathMajorVersion(basePath, major string) string {
 	m := pathMajorVersionRE.FindStringSubmatch(basePath)
 	if len(m) == 0 {
-		return ""
+		if major == "v0" || major == "v1" {
+			return basePath
+		}
+		return fmt.Sprintf("%s/%s", basePath, major)
 	}
 	sep := m[1]
 	return fmt.Sprintf("%s%s%s", basePath[:strings.LastIndex(basePath, sep)], sep, major)
@@ -115,10 +259,7 @@ func (u *Updater) checkForUpdate(ctx context.Context, dep updater.Dependency, fi
 		return nil, nil
 	}
 
-	var latestVersion = nfo.Version
-	if versCount := len(nfo.Versions); versCount > 0 {
-		latestVersion = nfo.Versions[versCount-1]
-	}
+	latestVersion := latestModuleVersion(nfo)
 
 	// Does this update progress the semver?
 	log = log.WithFields(logrus.Fields{
@@ -139,35 +280,11 @@ func (u *Updater) checkForUpdate(ctx context.Context, dep updater.Dependency, fi
 }
 
 func (u *Updater) queryModuleVersions(ctx context.Context, path string, filter func(string) bool) (*modinfo.ModulePublic, error) {
-	if closer, err := u.ensureGomodInRoot(); err != nil {
+	nfo, err := u.listModule(ctx, "-versions", path)
+	if err != nil {
 		return nil, err
-	} else if closer != nil {
-		defer func() {
-			if err := closer(); err != nil {
-				logrus.WithError(err).Warn("cleaning up temp go file")
-			}
-		}()
 	}
-
-	// Shell out to `go list` for the query, as this supports the same authentication the user's using for `go get`
-	var buf bytes.Buffer
-	var errBuf bytes.Buffer
-	cmd := exec.CommandContext(ctx, "go", "list", "-m", "-mod=mod", "-versions", "-json", path)
-	cmd.Stdout = &buf
-	cmd.Stderr = &errBuf
-	cmd.Dir = u.root
-	if err := cmd.Run(); err != nil {
-		errString := errBuf.String()
-		if !strings.Contains(errString, "no matching versions for query") {
-			logrus.WithField("stderr", errString).Warn("module versions query error")
-		}
-		return nil, fmt.Errorf("querying versions: %w", err)
-	}
-	var nfo modinfo.ModulePublic
-	if err := json.NewDecoder(&buf).Decode(&nfo); err != nil {
-		return nil, fmt.Errorf("decoding version query: %w", err)
-	}
-	if nfo.Version == "" {
+	if nfo.Version == "" && len(nfo.Versions) == 0 {
 		return nil, fmt.Errorf("invalid version response")
 	}
 
@@ -189,26 +306,38 @@ func (u *Updater) queryModuleVersions(ctx context.Context, path string, filter f
 		return nil, nil
 	}
 
-	return &nfo, nil
+	return &nfo.ModulePublic, nil
 }
 
-var dummyModFile = []byte(`module dummy`)
+// listedModule is module information reported by `go list -m -json`.
+type listedModule struct {
+	modinfo.ModulePublic
+	// Origin is the VCS source of the version, if known
+	Origin *moduleOrigin `json:",omitempty"`
+}
 
-func (u *Updater) ensureGomodInRoot() (func() error, error) {
-	// Check for go.mod file
-	gomodPath := filepath.Join(u.root, GoModFn)
-	_, err := os.Stat(gomodPath)
-	if err == nil {
-		return nil, nil
-	} else if !os.IsNotExist(err) {
-		return nil, fmt.Errorf("stat go.mod: %w", err)
-	}
+// moduleOrigin is the VCS source of a module version.
+type moduleOrigin struct {
+	VCS  string
+	URL  string
+	Hash string
+	Ref  string
+}
 
-	// Not found, write a dummy file
-	if err := ioutil.WriteFile(gomodPath, dummyModFile, 0600); err != nil {
-		return nil, fmt.Errorf("writing dummy go file")
+// listModule queries module information with `go list -m`, e.g. listModule(ctx, "-versions", "github.com/foo/bar")
+func (u *Updater) listModule(ctx context.Context, args ...string) (*listedModule, error) {
+	// Shell out to `go list` for the query, as this supports the same authentication the user's using for `go get`
+	buf, errBuf, err := runInScratchModule(ctx, append([]string{"list", "-m", "-mod=mod", "-json"}, args...)...)
+	if err != nil {
+		errString := errBuf.String()
+		if !strings.Contains(errString, "no matching versions for query") {
+			logrus.WithField("stderr", errString).Warn("module versions query error")
+		}
+		return nil, fmt.Errorf("querying versions: %w", err)
 	}
-	return func() error {
-		return os.Remove(gomodPath)
-	}, nil
+	var nfo listedModule
+	if err := json.NewDecoder(buf).Decode(&nfo); err != nil {
+		return nil, fmt.Errorf("decoding version query: %w", err)
+	}
+	return &nfo, nil
 }
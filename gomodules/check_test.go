@@ -1,9 +1,15 @@
 package gomodules_test
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,6 +57,34 @@ func TestUpdater_Check_GopkgIn(t *testing.T) {
 	assert.NotEqual(t, "v1", semver.Major(u.Next))
 }
 
+func TestUpdater_Check_Unsuffixed(t *testing.T) {
+	u := updatertest.CheckInFixture(t, "unsuffixed", updaterFactory(gomodules.WithMajorVersions(true)), updater.Dependency{
+		Path:    "github.com/urfave/cli",
+		Version: "v1.22.5",
+	}, nil)
+	require.NotNil(t, u)
+	t.Log(u.Next)
+	assert.True(t, semver.Compare("v2", semver.Major(u.Next)) <= 0)
+}
+
+func TestUpdater_Check_MaxMajorJump(t *testing.T) {
+	cli := updater.Dependency{
+		Path:    "github.com/urfave/cli",
+		Version: "v1.22.5",
+	}
+
+	// urfave/cli has released /v2 and /v3, skipping to the latest:
+	u := updatertest.CheckInFixture(t, "unsuffixed", updaterFactory(gomodules.WithMajorVersions(true)), cli, nil)
+	require.NotNil(t, u)
+	t.Log(u.Next)
+	assert.True(t, semver.Compare("v3", semver.Major(u.Next)) <= 0)
+
+	u = updatertest.CheckInFixture(t, "unsuffixed", updaterFactory(gomodules.WithMajorVersions(true), gomodules.WithMaxMajorJump(1)), cli, nil)
+	require.NotNil(t, u)
+	t.Log(u.Next)
+	assert.Equal(t, "v2", semver.Major(u.Next))
+}
+
 func TestUpdater_Check_MajorVersionsNotAvailable(t *testing.T) {
 	t.Skip("expects v32 to be the latest, check https://github.com/google/go-github/tags before running")
 	latestGoGitHubMajor := updater.Dependency{
@@ -77,3 +111,445 @@ func TestUpdater_Check_Multimodule(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdater_Check_AlignVersions(t *testing.T) {
+	localProxy(t)
+	align := updater.Dependency{Path: "example.com/align", Version: "v1.0.0"}
+	// Reject every release, the only candidate is the commit another module already requires:
+	const required = "v1.1.1-0.20200101000000-abcdefabcdef"
+	onlyRequired := func(v string) bool { return v == required }
+
+	u := updatertest.CheckInFixture(t, "unalignedpseudo", updaterFactory(gomodules.WithAlignVersions(true)), align, onlyRequired)
+	require.NotNil(t, u)
+	assert.Equal(t, required, u.Next)
+
+	u = updatertest.CheckInFixture(t, "unalignedpseudo", updaterFactory(gomodules.WithAlignVersions(false)), align, onlyRequired)
+	assert.Nil(t, u)
+}
+
+func TestUpdater_Check_PseudoVersions(t *testing.T) {
+	pseudoErrors := updater.Dependency{
+		Path:    "github.com/pkg/errors",
+		Version: "v0.9.2-0.20200217224146-7f95ac13edff",
+	}
+
+	// Skipped by default:
+	u := updatertest.CheckInFixture(t, "simple", updaterFactory(), pseudoErrors, nil)
+	assert.Nil(t, u)
+
+	u = updatertest.CheckInFixture(t, "simple", updaterFactory(gomodules.WithPseudoVersions(true)), pseudoErrors, nil)
+	require.NotNil(t, u)
+	t.Log(u.Next)
+	assert.True(t, semver.Compare(pseudoErrors.Version, u.Next) < 0)
+
+	// Commits younger than the minimum age are not proposed:
+	u = updatertest.CheckInFixture(t, "simple", updaterFactory(gomodules.WithPseudoVersions(true), gomodules.WithPseudoVersionMinAge(100*365*24*time.Hour)), pseudoErrors, nil)
+	assert.Nil(t, u)
+}
+
+func TestUpdater_Check_PromotePseudoVersions(t *testing.T) {
+	pinned, unmerged := releaseFixture(t)
+
+	cases := map[string]struct {
+		version string
+		next    string
+	}{
+		"released": {version: pinned, next: "v1.0.1"},
+		"unmerged": {version: unmerged},
+	}
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			dep := updater.Dependency{Path: "example.com/promo", Version: c.version}
+			u := updatertest.CheckInFixture(t, "localproxy", updaterFactory(gomodules.WithPromotePseudoVersions(true)), dep, nil)
+			if c.next == "" {
+				assert.Nil(t, u)
+				return
+			}
+			require.NotNil(t, u)
+			assert.Equal(t, c.next, u.Next)
+		})
+	}
+}
+
+// forkedRelease is a version of example.com/fork based on the latest release of example.com/promo.
+const forkedRelease = "v1.0.1-fork.1"
+
+// releaseFixture serves example.com/promo from a local git repository and module proxy.
+// Returns pseudo-versions of a commit released as v1.0.1, and of a commit on an unmerged branch.
+// Both commits are also served as example.com/fork, a fork of example.com/promo, along with a fork of v1.0.1.
+func releaseFixture(t *testing.T) (pinned, unmerged string) {
+	repo, git := gitFixture(t, "promo", "main", "v1.0.0")
+	commit := func(msg string) {
+		f, err := os.OpenFile(filepath.Join(repo, "promo.go"), os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = fmt.Fprintf(f, "// %s\n", msg)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		git("commit", "-q", "-am", msg)
+	}
+	pseudoVersion := func() string {
+		sec, err := strconv.ParseInt(git("log", "-1", "--format=%ct"), 10, 64)
+		require.NoError(t, err)
+		return fmt.Sprintf("v1.0.1-0.%s-%s", time.Unix(sec, 0).UTC().Format("20060102150405"), git("rev-parse", "--short=12", "HEAD"))
+	}
+
+	git("checkout", "-q", "-b", "unmerged")
+	commit("unmerged")
+	unmerged = pseudoVersion()
+
+	git("checkout", "-q", "main")
+	commit("fix")
+	pinned = pseudoVersion()
+	commit("release")
+	git("tag", "v1.0.1")
+
+	promo := map[string]proxyVersion{}
+	for _, v := range []string{"v1.0.0", "v1.0.1"} {
+		promo[v] = proxyVersion{
+			GoMod: git("show", v+":go.mod") + "\n",
+			Info: fmt.Sprintf(`{"Version":%q,"Time":%q,"Origin":{"VCS":"git","URL":%q,"Hash":%q,"Ref":"refs/tags/%s"}}`,
+				v, git("log", "-1", "--format=%cI", v), repo, git("rev-parse", v), v),
+		}
+	}
+	proxy := localProxy(t)
+	proxy.serve("example.com/promo", promo)
+	forkMod := proxyVersion{GoMod: "module example.com/promo\n"}
+	proxy.serve("example.com/fork", map[string]proxyVersion{pinned: forkMod, unmerged: forkMod, forkedRelease: forkMod})
+	return pinned, unmerged
+}
+
+func TestUpdater_Check_Exclude(t *testing.T) {
+	// The nested module excludes the latest release:
+	u := updatertest.CheckInFixture(t, "exclude", updaterFactory(), updater.Dependency{
+		Path:    "github.com/pkg/errors",
+		Version: "v0.8.0",
+	}, nil)
+	require.NotNil(t, u)
+	assert.Equal(t, "v0.9.0", u.Next)
+}
+
+func TestUpdater_Check_ExcludeMajor(t *testing.T) {
+	localProxy(t)
+	// The only release of example.com/refresh/v2 is excluded:
+	u := updatertest.CheckInFixture(t, "excludemajor", updaterFactory(gomodules.WithMajorVersions(true)), updater.Dependency{
+		Path:    "example.com/refresh",
+		Version: "v1.0.0",
+	}, nil)
+	require.NotNil(t, u)
+	assert.Equal(t, "v1.1.0", u.Next)
+}
+
+func TestUpdater_Check_TrackForks(t *testing.T) {
+	pinned, unmerged := releaseFixture(t)
+
+	cases := map[string]struct {
+		fork    string
+		updated bool
+	}{
+		"released": {fork: pinned, updated: true},
+		// The fork's commit is not released, so the first release after the fork's base is proposed:
+		"unmerged":   {fork: unmerged, updated: true},
+		"up to date": {fork: forkedRelease},
+	}
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			tempDir := forkFixture(t, c.fork)
+			u := gomodules.NewUpdater(tempDir, gomodules.WithTrackForks(true))
+
+			update, err := u.Check(context.Background(), updater.Dependency{Path: "example.com/fork", Version: c.fork}, nil)
+			require.NoError(t, err)
+			if !c.updated {
+				assert.Nil(t, update)
+				return
+			}
+			require.NotNil(t, update)
+			assert.Equal(t, updater.Update{
+				Path:     "example.com/promo",
+				Previous: "v1.0.0",
+				Next:     "v1.0.1",
+			}, *update)
+		})
+	}
+}
+
+// forkFixture returns a module that replaces example.com/promo with a version of example.com/fork.
+func forkFixture(t *testing.T, forkVersion string) string {
+	tempDir := updatertest.TempDirFromFixture(t, "fork")
+	f, err := os.OpenFile(filepath.Join(tempDir, gomodules.GoModFn), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(f, "\nreplace example.com/promo => example.com/fork %s\n", forkVersion)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return tempDir
+}
+
+// siblingTags are tags of the siblings fixture, for the common module and the root module.
+var siblingTags = []string{"common/v1.1.0", "common/v1.2.0", "common/v2.0.0", "v3.0.0"}
+
+func TestUpdater_Check_SiblingModules(t *testing.T) {
+	tempDir, _ := gitFixture(t, "siblings", "main", siblingTags...)
+	common := updater.Dependency{Path: "github.com/thepwagner/action-update-go/siblings/common", Version: "v1.0.0"}
+
+	// Tags for other modules and major versions are ignored:
+	u := gomodules.NewUpdater(tempDir, gomodules.WithSiblingModules(true))
+	update, err := u.Check(context.Background(), common, nil)
+	require.NoError(t, err)
+	require.NotNil(t, update)
+	assert.Equal(t, "v1.2.0", update.Next)
+}
+
+func TestUpdater_Check_IndirectBatch(t *testing.T) {
+	tempDir := updatertest.TempDirFromFixture(t, "indirect")
+	u := gomodules.NewUpdater(tempDir, gomodules.WithIndirectPolicy(gomodules.IndirectBatch))
+	deps, err := u.Dependencies(context.Background())
+	require.NoError(t, err)
+	batch := deps[len(deps)-1]
+	require.Equal(t, gomodules.IndirectBatchPath, batch.Path)
+
+	update, err := u.Check(context.Background(), batch, nil)
+	require.NoError(t, err)
+	require.NotNil(t, update)
+	assert.Equal(t, gomodules.IndirectBatchPath, update.Path)
+	assert.Equal(t, batch.Version, update.Previous)
+	assert.NotEqual(t, batch.Version, update.Next)
+}
+
+func TestUpdater_Check_Refresh(t *testing.T) {
+	localProxy(t)
+	tempDir := updatertest.TempDirFromFixture(t, "refresh")
+
+	for _, mode := range []gomodules.RefreshMode{gomodules.RefreshPatch, gomodules.RefreshMinor} {
+		t.Run(string(mode), func(t *testing.T) {
+			u := gomodules.NewUpdater(tempDir, gomodules.WithRefresh(mode))
+			deps, err := u.Dependencies(context.Background())
+			require.NoError(t, err)
+			require.Len(t, deps, 2)
+			refresh := deps[1]
+			require.Equal(t, gomodules.RefreshBatchPath, refresh.Path)
+
+			update, err := u.Check(context.Background(), refresh, nil)
+			require.NoError(t, err)
+			require.NotNil(t, update)
+			assert.Equal(t, gomodules.RefreshBatchPath, update.Path)
+			assert.Equal(t, refresh.Version, update.Previous)
+			assert.NotEqual(t, refresh.Version, update.Next)
+		})
+	}
+}
+
+func TestUpdater_Check_RefreshSkipped(t *testing.T) {
+	localProxy(t)
+	refresh := updater.Dependency{Path: gomodules.RefreshBatchPath}
+
+	cases := map[string]struct {
+		fixture string
+		opts    []gomodules.UpdaterOpt
+		config  string
+	}{
+		// The only module that would move is pinned, or a skipped tool:
+		"pinned": {fixture: "refresh", config: "strategies:\n  - pattern: example.com/refresh\n    strategy: pinned\n"},
+		"tool":   {fixture: "tools", opts: []gomodules.UpdaterOpt{gomodules.WithToolPolicy(gomodules.ToolSkip)}},
+	}
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			tempDir := updatertest.TempDirFromFixture(t, c.fixture)
+			if c.config != "" {
+				writeFixtureFile(t, tempDir, gomodules.StrategyConfigFn, c.config)
+			}
+			u := gomodules.NewUpdater(tempDir, append(c.opts, gomodules.WithRefresh(gomodules.RefreshMinor))...)
+
+			update, err := u.Check(context.Background(), refresh, nil)
+			require.NoError(t, err)
+			assert.Nil(t, update)
+		})
+	}
+}
+
+func TestUpdater_Check_LeavesRepositoryUnchanged(t *testing.T) {
+	localProxy(t)
+	dep := updater.Dependency{Path: "example.com/refresh", Version: "v1.0.0"}
+
+	cases := map[string][]updater.Dependency{
+		"refresh":     {dep, {Path: gomodules.RefreshBatchPath}},
+		"multimodule": {dep},
+	}
+	for fixture, deps := range cases {
+		t.Run(fixture, func(t *testing.T) {
+			tempDir := updatertest.TempDirFromFixture(t, fixture)
+			before := readTree(t, tempDir)
+
+			u := gomodules.NewUpdater(tempDir, gomodules.WithRefresh(gomodules.RefreshPatch))
+			for _, d := range deps {
+				_, err := u.Check(context.Background(), d, nil)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, before, readTree(t, tempDir))
+		})
+	}
+}
+
+// readTree returns the content of every file in a directory, by relative path.
+func readTree(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = string(b)
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestUpdater_Check_Strategy(t *testing.T) {
+	localProxy(t)
+	dep := updater.Dependency{Path: "example.com/strategy", Version: "v1.0.0"}
+
+	cases := map[string]struct {
+		config string
+		next   string
+	}{
+		"none":    {next: "v2.0.0"},
+		"patch":   {config: "  - pattern: example.com/\n    strategy: patch\n", next: "v1.0.1"},
+		"minor":   {config: "  - pattern: example.com/strategy\n    strategy: minor\n", next: "v1.1.0"},
+		"pinned":  {config: "  - pattern: /^example\\.com/\n    strategy: pinned\n"},
+		"first":   {config: "  - pattern: example.com/\n    strategy: patch\n  - pattern: example.com/\n    strategy: pinned\n", next: "v1.0.1"},
+		"nomatch": {config: "  - pattern: /other/\n    strategy: pinned\n", next: "v2.0.0"},
+	}
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			tempDir := updatertest.TempDirFromFixture(t, "strategy")
+			if c.config != "" {
+				writeFixtureFile(t, tempDir, gomodules.StrategyConfigFn, "strategies:\n"+c.config)
+			}
+
+			u, err := gomodules.NewUpdater(tempDir).Check(context.Background(), dep, nil)
+			require.NoError(t, err)
+			if c.next == "" {
+				assert.Nil(t, u)
+				return
+			}
+			require.NotNil(t, u)
+			assert.Equal(t, c.next, u.Next)
+		})
+	}
+}
+
+func TestUpdater_Check_StrategyInvalid(t *testing.T) {
+	tempDir := updatertest.TempDirFromFixture(t, "strategy")
+	writeFixtureFile(t, tempDir, gomodules.StrategyConfigFn, "strategies:\n  - pattern: example.com/\n    strategy: sometimes\n")
+
+	u := gomodules.NewUpdater(tempDir)
+	_, err := u.Dependencies(context.Background())
+	assert.EqualError(t, err, `invalid strategy config: invalid strategy "sometimes" for pattern "example.com/"`)
+	_, err = u.Check(context.Background(), updater.Dependency{Path: "example.com/strategy", Version: "v1.0.0"}, nil)
+	assert.EqualError(t, err, `invalid strategy config: invalid strategy "sometimes" for pattern "example.com/"`)
+}
+
+func TestUpdater_Check_StrategyBaseBranches(t *testing.T) {
+	localProxy(t)
+	ctx := context.Background()
+	dep := updater.Dependency{Path: "example.com/strategy", Version: "v1.0.0"}
+
+	// Only the release branch restricts updates to patches:
+	tempDir, git := gitFixture(t, "strategy", "main")
+	git("checkout", "-q", "-b", "release/1.0")
+	writeFixtureFile(t, tempDir, gomodules.StrategyConfigFn, "strategies:\n  - pattern: example.com/\n    strategy: patch\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "patches only")
+
+	// The same updater visits each base branch, following the rules of that branch:
+	u := gomodules.NewUpdater(tempDir)
+	for _, c := range []struct{ branch, next string }{
+		{branch: "main", next: "v2.0.0"},
+		{branch: "release/1.0", next: "v1.0.1"},
+		{branch: "main", next: "v2.0.0"},
+	} {
+		git("checkout", "-q", c.branch)
+		_, err := u.Dependencies(ctx)
+		require.NoError(t, err)
+		update, err := u.Check(ctx, dep, nil)
+		require.NoError(t, err)
+		require.NotNil(t, update, c.branch)
+		assert.Equal(t, c.next, update.Next, c.branch)
+	}
+}
+
+func TestUpdater_Check_StrategyLatest(t *testing.T) {
+	localProxy(t)
+	dep := updater.Dependency{Path: "example.com/strategy", Version: "v1.0.0"}
+
+	// `latest` only proposes major versions if they are enabled:
+	cases := map[bool]string{
+		true:  "v2.0.0",
+		false: "v1.1.0",
+	}
+	for majorVersions, next := range cases {
+		t.Run(fmt.Sprintf("major versions %v", majorVersions), func(t *testing.T) {
+			tempDir := updatertest.TempDirFromFixture(t, "strategy")
+			writeFixtureFile(t, tempDir, gomodules.StrategyConfigFn, "strategies:\n  - pattern: example.com/\n    strategy: latest\n")
+
+			u, err := gomodules.NewUpdater(tempDir, gomodules.WithMajorVersions(majorVersions)).Check(context.Background(), dep, nil)
+			require.NoError(t, err)
+			require.NotNil(t, u)
+			assert.Equal(t, next, u.Next)
+		})
+	}
+}
+
+func TestUpdater_Check_GoVersion(t *testing.T) {
+	localProxy(t)
+	dep := updater.Dependency{Path: gomodules.GoVersionPath, Version: "v1.20"}
+
+	u := updatertest.CheckInFixture(t, "goversion", updaterFactory(gomodules.WithGoVersion(true)), dep, nil)
+	require.NotNil(t, u)
+	assert.Equal(t, "v1.21.5", u.Next)
+
+	notPatch := func(v string) bool { return v != "v1.21.5" }
+	u = updatertest.CheckInFixture(t, "goversion", updaterFactory(gomodules.WithGoVersion(true)), dep, notPatch)
+	require.NotNil(t, u)
+	assert.Equal(t, "v1.21.0", u.Next)
+}
+
+func TestUpdater_Check_GoVersionPolicy(t *testing.T) {
+	localProxy(t)
+	dep := updater.Dependency{Path: "example.com/gover", Version: "v1.0.0"}
+
+	cases := map[string]struct {
+		policy        gomodules.GoVersionPolicy
+		majorVersions bool
+		next          string
+	}{
+		"ignore":            {policy: gomodules.GoVersionIgnore, majorVersions: true, next: "v3.0.0"},
+		"skip":              {policy: gomodules.GoVersionSkip, majorVersions: true},
+		"compatible":        {policy: gomodules.GoVersionCompatible, majorVersions: true, next: "v2.0.0"},
+		"compatible minors": {policy: gomodules.GoVersionCompatible, next: "v1.1.0"},
+		"bump":              {policy: gomodules.GoVersionBump, majorVersions: true, next: "v3.0.0"},
+	}
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			factory := updaterFactory(gomodules.WithGoVersionPolicy(c.policy), gomodules.WithMajorVersions(c.majorVersions))
+			u := updatertest.CheckInFixture(t, "gorequirement", factory, dep, nil)
+			if c.next == "" {
+				assert.Nil(t, u)
+				return
+			}
+			require.NotNil(t, u)
+			assert.Equal(t, c.next, u.Next)
+		})
+	}
+}
+
+func TestUpdater_Check_PinnedTools(t *testing.T) {
+	localProxy(t)
+	tool := updater.Dependency{Path: "example.com/tool", Version: "v1.0.0"}
+	u := updatertest.CheckInFixture(t, "pinned", updaterFactory(gomodules.WithPinnedTools(true)), tool, nil)
+	require.NotNil(t, u)
+	assert.Equal(t, "v1.1.0", u.Next)
+}
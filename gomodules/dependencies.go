@@ -7,59 +7,260 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/sirupsen/logrus"
 	"github.com/thepwagner/action-update/updater"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
 )
 
-func (u *Updater) Dependencies(_ context.Context) ([]updater.Dependency, error) {
+func (u *Updater) Dependencies(ctx context.Context) ([]updater.Dependency, error) {
 	goModFiles, err := u.collectGoModFiles()
 	if err != nil {
 		return nil, err
 	}
 	logrus.WithField("gomods", len(goModFiles)).Debug("discovered go.mod files")
 
+	// Fork replacements are only dropped by updates proposed since the last run:
+	u.forkProposals = nil
+	// Dependencies are listed from the base branch of this run, which decides how sibling modules are updated:
+	if u.SiblingModules && len(u.ReleaseBranches) > 0 {
+		if u.baseBranch, err = u.currentBranch(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Each run reads the strategy config of its base branch, reporting an invalid config once rather than from every Check:
+	if err := u.loadStrategyRules(); err != nil {
+		return nil, err
+	}
+
 	deps, err := u.collectUniqueDependencies(goModFiles)
 	if err != nil {
 		return nil, err
 	}
+	var pinned []updater.Dependency
+	if u.PinnedTools {
+		if pinned, err = u.pinnedDependencies(ctx); err != nil {
+			return nil, fmt.Errorf("collecting pinned tools: %w", err)
+		}
+		for _, d := range pinned {
+			if _, ok := deps[dependencyKey(d)]; !ok {
+				deps[dependencyKey(d)] = &requirement{Dependency: d}
+			}
+		}
+	}
+	if u.AlignVersions {
+		deps = alignDependencies(deps)
+	}
+	// Batches of non-tool modules and Go releases are not tools, so they are only listed beside other modules:
+	var refresh *requirement
+	if u.Refresh != "" && u.ToolPolicy != ToolOnly {
+		// The refresh covers every module, regardless of the indirect policy:
+		refresh = refreshDependency(deps)
+	}
+	deps = u.filterIndirect(deps)
+	if u.ToolPolicy == ToolSkip || u.ToolPolicy == ToolOnly {
+		tools, err := u.toolModules(goModFiles)
+		if err != nil {
+			return nil, fmt.Errorf("collecting tools: %w", err)
+		}
+		for _, d := range pinned {
+			tools[d.Path] = true
+		}
+		deps = u.filterTools(deps, tools)
+	}
+	if refresh != nil {
+		deps[RefreshBatchPath] = refresh
+	}
+	if u.GoVersion && u.ToolPolicy != ToolOnly {
+		goVersion, err := u.goVersionDependency(goModFiles)
+		if err != nil {
+			return nil, err
+		} else if goVersion != nil {
+			deps[GoVersionPath] = goVersion
+		}
+	}
 
 	return sortUniqueDependencies(deps)
 }
 
 func (u *Updater) collectGoModFiles() ([]string, error) {
-	gomods, err := filepath.Glob(filepath.Join(u.root, "**", GoModFn))
+	// Workspaces list their modules explicitly:
+	work, err := u.parseGoWork()
 	if err != nil {
-		return nil, fmt.Errorf("collecting go.mod: %w", err)
+		return nil, err
+	} else if work != nil {
+		return u.workspaceGoModFiles(work), nil
 	}
-	rootGomod := filepath.Join(u.root, GoModFn)
-	if _, err := os.Stat(rootGomod); err == nil {
-		gomods = append(gomods, rootGomod)
+
+	var gomods []string
+	err = filepath.Walk(u.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != u.root && u.skipDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == GoModFn {
+			gomods = append(gomods, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collecting go.mod: %w", err)
 	}
 	return gomods, nil
 }
 
-func (u *Updater) collectUniqueDependencies(gomods []string) (map[string]updater.Dependency, error) {
-	deps := map[string]updater.Dependency{}
+// skipDir returns true if a directory should not be searched for modules.
+func (u *Updater) skipDir(path string) bool {
+	name := filepath.Base(path)
+	switch {
+	case name == "vendor", name == "testdata":
+		return true
+	case strings.HasPrefix(name, "."):
+		return true
+	}
+
+	rel, err := filepath.Rel(u.root, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range u.Exclude {
+		if m, _ := doublestar.Match(pattern, rel); m {
+			logrus.WithField("path", rel).Debug("excluded directory")
+			return true
+		}
+	}
+	return false
+}
+
+// requirement is a dependency, and the go.mod files that require it.
+type requirement struct {
+	updater.Dependency
+	GoMods []string
+}
+
+func (u *Updater) collectUniqueDependencies(gomods []string) (map[string]*requirement, error) {
+	work, err := u.parseGoWork()
+	if err != nil {
+		return nil, err
+	}
+	var workReplace []*modfile.Replace
+	if work != nil {
+		workReplace = work.Replace
+	}
+
+	var siblings map[string]string
+	if u.SiblingModules {
+		if siblings, err = u.siblingModules(gomods); err != nil {
+			return nil, err
+		}
+	}
+
+	deps := map[string]*requirement{}
 	for _, gomod := range gomods {
 		parsed, err := u.parseGoMod(gomod)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, d := range extractDependencies(parsed) {
+		extracted := extractDependencies(parsed, workReplace...)
+		if siblings != nil {
+			extracted = append(extracted, siblingDependencies(gomod, parsed, siblings)...)
+		}
+		for _, d := range extracted {
 			if d.Version == "" {
 				// Modules without versions are path replacements we can't affect:
 				continue
 			}
-			depKey := fmt.Sprintf("%s-%s", d.Path, d.Version)
-			deps[depKey] = d
+			depKey := dependencyKey(d)
+			req, ok := deps[depKey]
+			if !ok {
+				req = &requirement{Dependency: d}
+				deps[depKey] = req
+			}
+			// Direct in any module is direct:
+			req.Indirect = req.Indirect && d.Indirect
+			req.GoMods = append(req.GoMods, gomod)
 		}
 	}
 	return deps, nil
 }
 
+func dependencyKey(d updater.Dependency) string {
+	return fmt.Sprintf("%s-%s", d.Path, d.Version)
+}
+
+// alignDependencies merges requirements of the same path, keeping the oldest version.
+func alignDependencies(deps map[string]*requirement) map[string]*requirement {
+	aligned := make(map[string]*requirement, len(deps))
+	for _, req := range deps {
+		existing, ok := aligned[req.Path]
+		if !ok {
+			aligned[req.Path] = &requirement{
+				Dependency: req.Dependency,
+				GoMods:     append([]string(nil), req.GoMods...),
+			}
+			continue
+		}
+		if semver.Compare(req.Version, existing.Version) < 0 {
+			existing.Version = req.Version
+		}
+		existing.Indirect = existing.Indirect && req.Indirect
+		existing.GoMods = append(existing.GoMods, req.GoMods...)
+	}
+	return aligned
+}
+
+// requiredVersions returns the version of a path required by each go.mod file.
+func (u *Updater) requiredVersions(path string) (map[string]string, error) {
+	goModFiles, err := u.collectGoModFiles()
+	if err != nil {
+		return nil, err
+	}
+	deps, err := u.collectUniqueDependencies(goModFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, req := range deps {
+		if req.Path != path {
+			continue
+		}
+		for _, gomod := range req.GoMods {
+			versions[gomod] = req.Version
+		}
+	}
+	return versions, nil
+}
+
+// requiringGoModFiles returns the go.mod files that require the path of an update, at an older version.
+func (u *Updater) requiringGoModFiles(update updater.Update) ([]string, error) {
+	versions, err := u.requiredVersions(update.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(versions))
+	for gomod, version := range versions {
+		if update.Next != "" && semver.Compare(version, update.Next) >= 0 {
+			continue
+		}
+		ret = append(ret, gomod)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
 func (u *Updater) parseGoMod(path string) (*modfile.File, error) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -72,14 +273,17 @@ func (u *Updater) parseGoMod(path string) (*modfile.File, error) {
 	return parsed, nil
 }
 
-func extractDependencies(parsed *modfile.File) []updater.Dependency {
-	replacements := indexReplacements(parsed)
-
+// extractDependencies returns the requirements of a go.mod file, after replacements.
+// Workspace replacements take precedence over the go.mod file's own replacements.
+func extractDependencies(parsed *modfile.File, workReplace ...*modfile.Replace) []updater.Dependency {
 	deps := make([]updater.Dependency, 0, len(parsed.Require))
 	for _, req := range parsed.Require {
-		if replacement, ok := replacements[req.Mod.Path]; ok {
-			replacement.Indirect = req.Indirect
-			deps = append(deps, replacement)
+		if replacement := findReplacement(req.Mod.Path, req.Mod.Version, parsed.Replace, workReplace); replacement != nil {
+			deps = append(deps, updater.Dependency{
+				Path:     replacement.New.Path,
+				Version:  replacement.New.Version,
+				Indirect: req.Indirect,
+			})
 			continue
 		}
 
@@ -92,24 +296,39 @@ func extractDependencies(parsed *modfile.File) []updater.Dependency {
 	return deps
 }
 
-func indexReplacements(parsed *modfile.File) map[string]updater.Dependency {
-	replacements := map[string]updater.Dependency{}
-	for _, replace := range parsed.Replace {
-		replacements[replace.Old.Path] = updater.Dependency{
-			Path:    replace.New.Path,
-			Version: replace.New.Version,
+// findReplacement returns the replacement that applies to a required module version, or nil.
+// Replacement sets are in increasing order of precedence. Within a set, a replacement of the specific
+// version takes precedence over a replacement of every version.
+func findReplacement(path, version string, replaceSets ...[]*modfile.Replace) *modfile.Replace {
+	for i := len(replaceSets) - 1; i >= 0; i-- {
+		var allVersions *modfile.Replace
+		for _, replace := range replaceSets[i] {
+			if replace.Old.Path != path {
+				continue
+			}
+			if replace.Old.Version == version {
+				return replace
+			} else if replace.Old.Version == "" {
+				allVersions = replace
+			}
+		}
+		if allVersions != nil {
+			return allVersions
 		}
 	}
-	return replacements
+	return nil
 }
 
-func sortUniqueDependencies(deps map[string]updater.Dependency) ([]updater.Dependency, error) {
+func sortUniqueDependencies(deps map[string]*requirement) ([]updater.Dependency, error) {
 	ret := make([]updater.Dependency, 0, len(deps))
 	for _, d := range deps {
-		ret = append(ret, d)
+		ret = append(ret, d.Dependency)
 	}
 	sort.Slice(ret, func(i, j int) bool {
-		return ret[i].Path < ret[j].Path
+		if ret[i].Path != ret[j].Path {
+			return ret[i].Path < ret[j].Path
+		}
+		return semver.Compare(ret[i].Version, ret[j].Version) < 0
 	})
 	return ret, nil
 }
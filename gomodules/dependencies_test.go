@@ -1,8 +1,14 @@
 package gomodules_test
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thepwagner/action-update-go/gomodules"
 	"github.com/thepwagner/action-update/updater"
 	"github.com/thepwagner/action-update/updatertest"
 )
@@ -26,16 +32,33 @@ func TestUpdater_Dependencies_Fixtures(t *testing.T) {
 		"multimodule/cmd": {
 			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
 		},
+		"nested": {
+			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
+			{Path: "github.com/sirupsen/logrus", Version: "v1.5.0"},
+			{Path: "github.com/stretchr/testify", Version: "v1.6.1"},
+		},
 		"notinroot": {
 			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
 		},
 		"replace": {
 			{Path: "github.com/thepwagner/errors", Version: "v0.8.0"},
 		},
+		"replaceversion": {
+			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
+			{Path: "github.com/thepwagner/errors", Version: "v0.8.0"},
+		},
 		"simple": {
 			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
 			{Path: "github.com/sirupsen/logrus", Version: "v1.5.0"},
 		},
+		"workspace": {
+			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
+			{Path: "github.com/sirupsen/logrus", Version: "v1.6.0"},
+		},
+		"unaligned": {
+			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
+			{Path: "github.com/pkg/errors", Version: "v0.8.1"},
+		},
 		"vendor": {
 			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
 		},
@@ -43,3 +66,125 @@ func TestUpdater_Dependencies_Fixtures(t *testing.T) {
 
 	updatertest.DependenciesFixtures(t, updaterFactory(), cases)
 }
+
+func TestUpdater_Dependencies_Exclude(t *testing.T) {
+	cases := map[string][]updater.Dependency{
+		"nested": {
+			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
+			{Path: "github.com/sirupsen/logrus", Version: "v1.5.0"},
+		},
+	}
+
+	updatertest.DependenciesFixtures(t, updaterFactory(gomodules.WithExclude("examples/**")), cases)
+}
+
+func TestUpdater_Dependencies_AlignVersions(t *testing.T) {
+	cases := map[string][]updater.Dependency{
+		"unaligned": {
+			{Path: "github.com/pkg/errors", Version: "v0.8.0"},
+		},
+	}
+
+	updatertest.DependenciesFixtures(t, updaterFactory(gomodules.WithAlignVersions(true)), cases)
+}
+
+func TestUpdater_Dependencies_SiblingModules(t *testing.T) {
+	tempDir := updatertest.TempDirFromFixture(t, "siblings")
+
+	deps, err := gomodules.NewUpdater(tempDir).Dependencies(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, deps)
+
+	deps, err = gomodules.NewUpdater(tempDir, gomodules.WithSiblingModules(true)).Dependencies(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []updater.Dependency{
+		{Path: "github.com/thepwagner/action-update-go/siblings/common", Version: "v1.0.0"},
+	}, deps)
+}
+
+func TestUpdater_Dependencies_IndirectPolicy(t *testing.T) {
+	errors := updater.Dependency{Path: "github.com/pkg/errors", Version: "v0.8.0"}
+	logrus15 := updater.Dependency{Path: "github.com/sirupsen/logrus", Version: "v1.5.0", Indirect: true}
+	logrus16 := updater.Dependency{Path: "github.com/sirupsen/logrus", Version: "v1.6.0"}
+	testify := updater.Dependency{Path: "github.com/stretchr/testify", Version: "v1.5.1", Indirect: true}
+
+	cases := map[gomodules.IndirectPolicy][]updater.Dependency{
+		gomodules.IndirectInclude: {errors, logrus15, logrus16, testify},
+		gomodules.IndirectSkip:    {errors, logrus16},
+		gomodules.IndirectShared:  {errors, logrus15, logrus16},
+	}
+	for policy, expected := range cases {
+		t.Run(string(policy), func(t *testing.T) {
+			updatertest.DependenciesFixtures(t, updaterFactory(gomodules.WithIndirectPolicy(policy)), map[string][]updater.Dependency{
+				"indirect": expected,
+			})
+		})
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		tempDir := updatertest.TempDirFromFixture(t, "indirect")
+		deps, err := gomodules.NewUpdater(tempDir, gomodules.WithIndirectPolicy(gomodules.IndirectBatch)).Dependencies(context.Background())
+		require.NoError(t, err)
+		require.Len(t, deps, 3)
+		assert.Equal(t, errors, deps[0])
+		assert.Equal(t, logrus16, deps[1])
+		assert.Equal(t, gomodules.IndirectBatchPath, deps[2].Path)
+		assert.Len(t, deps[2].Version, 12)
+	})
+}
+
+func TestUpdater_Dependencies_ToolPolicy(t *testing.T) {
+	lib := updater.Dependency{Path: "example.com/lib", Version: "v1.0.0"}
+	tool := updater.Dependency{Path: "example.com/tool", Version: "v1.0.0"}
+
+	cases := map[gomodules.ToolPolicy]map[string][]updater.Dependency{
+		gomodules.ToolInclude: {"tools": {lib, tool}, "toolsgo": {tool}},
+		gomodules.ToolSkip:    {"tools": {lib}, "toolsgo": {}},
+		gomodules.ToolOnly:    {"tools": {tool}, "toolsgo": {tool}},
+	}
+	for policy, expected := range cases {
+		t.Run(string(policy), func(t *testing.T) {
+			updatertest.DependenciesFixtures(t, updaterFactory(gomodules.WithToolPolicy(policy)), expected)
+		})
+	}
+
+	// The refresh batch and Go releases are not tools:
+	onlyTools := updaterFactory(gomodules.WithToolPolicy(gomodules.ToolOnly), gomodules.WithRefresh(gomodules.RefreshPatch), gomodules.WithGoVersion(true))
+	updatertest.DependenciesFixtures(t, onlyTools, cases[gomodules.ToolOnly])
+}
+
+func TestUpdater_Dependencies_GoVersion(t *testing.T) {
+	cases := map[string][]updater.Dependency{
+		"goversion": {
+			{Path: "go", Version: "v1.20"},
+		},
+		"goversion/nested": {
+			{Path: "go", Version: "v1.21.3"},
+		},
+	}
+	updatertest.DependenciesFixtures(t, updaterFactory(gomodules.WithGoVersion(true)), cases)
+}
+
+func TestUpdater_Dependencies_PinnedTools(t *testing.T) {
+	localProxy(t)
+	cases := map[string][]updater.Dependency{
+		"pinned": {
+			{Path: "example.com/tool", Version: "v1.0.0"},
+		},
+	}
+	updatertest.DependenciesFixtures(t, updaterFactory(gomodules.WithPinnedTools(true)), cases)
+}
+
+func TestUpdater_Dependencies_PinnedToolsProxyError(t *testing.T) {
+	// Errors other than a missing module are returned, e.g. a proxy that denies access:
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer proxy.Close()
+	localProxy(t)
+	t.Setenv("GOPROXY", proxy.URL)
+
+	_, err := gomodules.NewUpdater(updatertest.TempDirFromFixture(t, "pinned"), gomodules.WithPinnedTools(true)).Dependencies(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403 Forbidden")
+}
@@ -1,15 +1,144 @@
 package gomodules
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/thepwagner/action-update/actions/updateaction"
 	"github.com/thepwagner/action-update/updater"
 )
 
 type Environment struct {
 	updateaction.Environment
-	Tidy bool `env:"INPUT_TIDY" envDefault:"true"`
+	Tidy                  bool          `env:"INPUT_TIDY" envDefault:"true"`
+	MajorVersions         bool          `env:"INPUT_MAJOR_VERSIONS" envDefault:"true"`
+	MaxMajorJump          int           `env:"INPUT_MAX_MAJOR_JUMP"`
+	RewriteReferences     string        `env:"INPUT_REWRITE_REFERENCES"`
+	RewriteTestdata       bool          `env:"INPUT_REWRITE_TESTDATA"`
+	AlignVersions         bool          `env:"INPUT_ALIGN_VERSIONS"`
+	ExcludeDirs           string        `env:"INPUT_EXCLUDE_DIRS"`
+	PseudoVersions        bool          `env:"INPUT_PSEUDO_VERSIONS"`
+	PseudoVersionBranch   string        `env:"INPUT_PSEUDO_VERSION_BRANCH"`
+	PseudoVersionMinAge   time.Duration `env:"INPUT_PSEUDO_VERSION_MIN_AGE"`
+	PromotePseudoVersions bool          `env:"INPUT_PROMOTE_PSEUDO_VERSIONS"`
+	DropStaleExcludes     bool          `env:"INPUT_DROP_STALE_EXCLUDES"`
+	TrackForks            bool          `env:"INPUT_TRACK_FORKS"`
+	SiblingModules        bool          `env:"INPUT_SIBLING_MODULES"`
+	ReleaseBranches       string        `env:"INPUT_RELEASE_BRANCHES"`
+	Indirect              string        `env:"INPUT_INDIRECT" envDefault:"include"`
+	Refresh               string        `env:"INPUT_REFRESH"`
+	GoVersion             bool          `env:"INPUT_GO_VERSION"`
+	PinnedTools           bool          `env:"INPUT_PINNED_TOOLS"`
+	GoVersionPolicy       string        `env:"INPUT_GO_VERSION_POLICY" envDefault:"ignore"`
+	Tools                 string        `env:"INPUT_TOOLS" envDefault:"include"`
+	VerifyTools           bool          `env:"INPUT_VERIFY_TOOLS"`
+}
+
+// Validate returns an error if inputs are invalid, or combined in a way that has no effect.
+func (c *Environment) Validate() error {
+	var errs []string
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if c.MaxMajorJump < 0 {
+		invalid("max_major_jump must not be negative: %d", c.MaxMajorJump)
+	} else if c.MaxMajorJump > 0 && !c.MajorVersions {
+		invalid("max_major_jump requires major_versions")
+	}
+	for _, kind := range strings.Fields(c.RewriteReferences) {
+		switch ReferenceKind(kind) {
+		case ReferenceDirectives, ReferenceStrings, ReferenceProto, ReferenceDocs:
+		default:
+			invalid("unknown rewrite_references kind %q, expected one of: %s, %s, %s, %s", kind, ReferenceDirectives, ReferenceStrings, ReferenceProto, ReferenceDocs)
+		}
+	}
+	if (c.RewriteReferences != "" || c.RewriteTestdata) && !c.MajorVersions {
+		invalid("rewrite_references and rewrite_testdata require major_versions")
+	}
+	for _, pattern := range strings.Fields(c.ExcludeDirs) {
+		if !doublestar.ValidatePattern(pattern) {
+			invalid("invalid exclude_dirs pattern %q", pattern)
+		}
+	}
+
+	if c.PseudoVersionMinAge < 0 {
+		invalid("pseudo_version_min_age must not be negative: %s", c.PseudoVersionMinAge)
+	}
+	if (c.PseudoVersionBranch != "" || c.PseudoVersionMinAge != 0) && !c.PseudoVersions {
+		invalid("pseudo_version_branch and pseudo_version_min_age require pseudo_versions")
+	}
+	for _, pattern := range strings.Fields(c.ReleaseBranches) {
+		if !doublestar.ValidatePattern(pattern) {
+			invalid("invalid release_branches pattern %q", pattern)
+		}
+	}
+	if c.ReleaseBranches != "" && !c.SiblingModules {
+		invalid("release_branches requires sibling_modules")
+	}
+
+	switch IndirectPolicy(c.Indirect) {
+	case "", IndirectInclude, IndirectSkip, IndirectBatch, IndirectShared:
+	default:
+		invalid("unknown indirect policy %q, expected one of: %s, %s, %s, %s", c.Indirect, IndirectInclude, IndirectSkip, IndirectBatch, IndirectShared)
+	}
+	switch GoVersionPolicy(c.GoVersionPolicy) {
+	case "", GoVersionIgnore, GoVersionSkip, GoVersionCompatible, GoVersionBump:
+	default:
+		invalid("unknown go_version_policy %q, expected one of: %s, %s, %s, %s", c.GoVersionPolicy, GoVersionIgnore, GoVersionSkip, GoVersionCompatible, GoVersionBump)
+	}
+	switch ToolPolicy(c.Tools) {
+	case "", ToolInclude, ToolOnly:
+	case ToolSkip:
+		if c.VerifyTools {
+			invalid("verify_tools has no effect when tools are skipped")
+		}
+	default:
+		invalid("unknown tools policy %q, expected one of: %s, %s, %s", c.Tools, ToolInclude, ToolSkip, ToolOnly)
+	}
+	switch RefreshMode(c.Refresh) {
+	case "", RefreshPatch, RefreshMinor:
+	default:
+		invalid("unknown refresh mode %q, expected one of: %s, %s", c.Refresh, RefreshPatch, RefreshMinor)
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid inputs: " + strings.Join(errs, "; "))
+	}
+	return nil
 }
 
 func (c *Environment) NewUpdater(root string) updater.Updater {
-	return NewUpdater(root, WithTidy(c.Tidy))
+	var references []ReferenceKind
+	for _, kind := range strings.Fields(c.RewriteReferences) {
+		references = append(references, ReferenceKind(kind))
+	}
+
+	return NewUpdater(root,
+		WithTidy(c.Tidy),
+		WithMajorVersions(c.MajorVersions),
+		WithMaxMajorJump(c.MaxMajorJump),
+		WithRewriteReferences(references...),
+		WithRewriteTestdata(c.RewriteTestdata),
+		WithAlignVersions(c.AlignVersions),
+		WithExclude(strings.Fields(c.ExcludeDirs)...),
+		WithPseudoVersions(c.PseudoVersions),
+		WithPseudoVersionBranch(c.PseudoVersionBranch),
+		WithPseudoVersionMinAge(c.PseudoVersionMinAge),
+		WithPromotePseudoVersions(c.PromotePseudoVersions),
+		WithDropStaleExcludes(c.DropStaleExcludes),
+		WithTrackForks(c.TrackForks),
+		WithSiblingModules(c.SiblingModules),
+		WithReleaseBranches(strings.Fields(c.ReleaseBranches)...),
+		WithIndirectPolicy(IndirectPolicy(c.Indirect)),
+		WithRefresh(RefreshMode(c.Refresh)),
+		WithGoVersion(c.GoVersion),
+		WithPinnedTools(c.PinnedTools),
+		WithGoVersionPolicy(GoVersionPolicy(c.GoVersionPolicy)),
+		WithToolPolicy(ToolPolicy(c.Tools)),
+		WithVerifyTools(c.VerifyTools),
+	)
 }
@@ -0,0 +1,90 @@
+package gomodules_test
+
+import (
+	"testing"
+	"time"
+
+	parser "github.com/caarlos0/env/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thepwagner/action-update-go/gomodules"
+)
+
+func TestEnvironment_NewUpdater(t *testing.T) {
+	t.Setenv("INPUT_MAJOR_VERSIONS", "true")
+	t.Setenv("INPUT_MAX_MAJOR_JUMP", "2")
+	t.Setenv("INPUT_REWRITE_REFERENCES", "directives\ndocs")
+	t.Setenv("INPUT_EXCLUDE_DIRS", "examples/**")
+	t.Setenv("INPUT_PSEUDO_VERSIONS", "true")
+	t.Setenv("INPUT_PSEUDO_VERSION_MIN_AGE", "168h")
+	t.Setenv("INPUT_SIBLING_MODULES", "true")
+	t.Setenv("INPUT_RELEASE_BRANCHES", "main release/*")
+	t.Setenv("INPUT_INDIRECT", "batch")
+	t.Setenv("INPUT_REFRESH", "patch")
+	t.Setenv("INPUT_TOOLS", "only")
+	t.Setenv("INPUT_VERIFY_TOOLS", "true")
+
+	var env gomodules.Environment
+	require.NoError(t, parser.Parse(&env))
+	require.NoError(t, env.Validate())
+
+	u := env.NewUpdater("").(*gomodules.Updater)
+	assert.True(t, u.Tidy)
+	assert.True(t, u.MajorVersions)
+	assert.Equal(t, 2, u.MaxMajorJump)
+	assert.Equal(t, []gomodules.ReferenceKind{gomodules.ReferenceDirectives, gomodules.ReferenceDocs}, u.RewriteReferences)
+	assert.Equal(t, []string{"examples/**"}, u.Exclude)
+	assert.True(t, u.PseudoVersions)
+	assert.Equal(t, 7*24*time.Hour, u.PseudoVersionMinAge)
+	assert.True(t, u.SiblingModules)
+	assert.Equal(t, []string{"main", "release/*"}, u.ReleaseBranches)
+	assert.Equal(t, gomodules.IndirectBatch, u.IndirectPolicy)
+	assert.Equal(t, gomodules.RefreshPatch, u.Refresh)
+	assert.Equal(t, gomodules.ToolOnly, u.ToolPolicy)
+	assert.True(t, u.VerifyTools)
+}
+
+func TestEnvironment_Validate(t *testing.T) {
+	cases := map[string]struct {
+		env gomodules.Environment
+		err string
+	}{
+		"defaults": {
+			env: gomodules.Environment{MajorVersions: true, Indirect: "include"},
+		},
+		"max major jump without majors": {
+			env: gomodules.Environment{MaxMajorJump: 1},
+			err: "invalid inputs: max_major_jump requires major_versions",
+		},
+		"unknown reference": {
+			env: gomodules.Environment{MajorVersions: true, RewriteReferences: "docs comments"},
+			err: `invalid inputs: unknown rewrite_references kind "comments", expected one of: directives, strings, proto, docs`,
+		},
+		"pseudo-version branch without pseudo-versions": {
+			env: gomodules.Environment{PseudoVersionBranch: "develop"},
+			err: "invalid inputs: pseudo_version_branch and pseudo_version_min_age require pseudo_versions",
+		},
+		"release branches without siblings": {
+			env: gomodules.Environment{ReleaseBranches: "release/*"},
+			err: "invalid inputs: release_branches requires sibling_modules",
+		},
+		"verify skipped tools": {
+			env: gomodules.Environment{MajorVersions: true, Tools: "skip", VerifyTools: true},
+			err: "invalid inputs: verify_tools has no effect when tools are skipped",
+		},
+		"multiple": {
+			env: gomodules.Environment{Indirect: "sometimes", Refresh: "major"},
+			err: `invalid inputs: unknown indirect policy "sometimes", expected one of: include, skip, batch, shared; unknown refresh mode "major", expected one of: patch, minor`,
+		},
+	}
+	for label, c := range cases {
+		t.Run(label, func(t *testing.T) {
+			err := c.env.Validate()
+			if c.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+}
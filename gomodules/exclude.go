@@ -0,0 +1,66 @@
+package gomodules
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// excludedVersions returns the versions of a path excluded by go.mod files that require a dependency.
+// The path is the dependency's, or another major version of it, e.g. excludedVersions("foo", "foo/v2").
+func (u *Updater) excludedVersions(depPath, path string) (map[string]struct{}, error) {
+	versions, err := u.requiredVersions(depPath)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[string]struct{}{}
+	for gomod := range versions {
+		parsed, err := u.parseGoMod(gomod)
+		if err != nil {
+			return nil, err
+		}
+		for _, ex := range parsed.Exclude {
+			if ex.Mod.Path == path {
+				excluded[ex.Mod.Version] = struct{}{}
+			}
+		}
+	}
+	return excluded, nil
+}
+
+// excludeFilter wraps a version filter to also reject excluded versions.
+func excludeFilter(filter func(string) bool, excluded map[string]struct{}) func(string) bool {
+	if len(excluded) == 0 {
+		return filter
+	}
+	return func(v string) bool {
+		if _, ok := excluded[v]; ok {
+			return false
+		}
+		return filter == nil || filter(v)
+	}
+}
+
+// dropStaleExcludes removes exclude directives that can no longer be selected after an update.
+// These are older versions of the path, or every version if a major update moved away from the path.
+func dropStaleExcludes(goMod *modfile.File, update updater.Update) error {
+	major := MajorPkg(update)
+	for _, ex := range goMod.Exclude {
+		if ex.Mod.Path != update.Path {
+			continue
+		}
+		if !major && semver.Compare(ex.Mod.Version, update.Next) >= 0 {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"path":    ex.Mod.Path,
+			"version": ex.Mod.Version,
+		}).Info("dropping stale exclude")
+		if err := goMod.DropExclude(ex.Mod.Path, ex.Mod.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
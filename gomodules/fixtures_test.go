@@ -0,0 +1,172 @@
+package gomodules_test
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thepwagner/action-update/updatertest"
+	"golang.org/x/mod/semver"
+)
+
+// proxyFixture is the fixture of module versions served by localProxy, laid out as <module path>@<version>.
+var proxyFixture = filepath.Join("testdata", "proxy")
+
+// proxyVersion is a version of a module served by a local module proxy.
+type proxyVersion struct {
+	// GoMod is the go.mod file of the version, by default only declaring the module path.
+	GoMod string
+	// Info is the .info file of the version, by default only the version.
+	Info string
+	// Files are additional files of the version, by name.
+	Files map[string]string
+}
+
+// moduleProxy is a local module proxy, used by go commands for the rest of a test.
+type moduleProxy struct {
+	t   *testing.T
+	dir string
+}
+
+// localProxy serves the module versions in testdata/proxy from a local module proxy, with an empty module cache.
+func localProxy(t *testing.T) *moduleProxy {
+	tempDir := t.TempDir()
+	p := &moduleProxy{t: t, dir: filepath.Join(tempDir, "proxy")}
+
+	modules := map[string]map[string]proxyVersion{}
+	err := filepath.Walk(proxyFixture, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		i := strings.LastIndex(info.Name(), "@")
+		if i < 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(proxyFixture, path)
+		if err != nil {
+			return err
+		}
+		modPath, version := filepath.ToSlash(rel[:len(rel)-len(info.Name())+i]), info.Name()[i+1:]
+		if modules[modPath] == nil {
+			modules[modPath] = map[string]proxyVersion{}
+		}
+		modules[modPath][version] = readProxyVersion(t, path)
+		return filepath.SkipDir
+	})
+	require.NoError(t, err)
+	for modPath, versions := range modules {
+		p.serve(modPath, versions)
+	}
+
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(p.dir))
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOMODCACHE", filepath.Join(tempDir, "modcache"))
+	t.Setenv("GOFLAGS", "-modcacherw")
+	return p
+}
+
+// readProxyVersion reads a module version from a directory of the proxy fixture.
+func readProxyVersion(t *testing.T, dir string) proxyVersion {
+	var pv proxyVersion
+	pv.Files = map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "go.mod" {
+			pv.GoMod = string(b)
+		} else {
+			pv.Files[filepath.ToSlash(rel)] = string(b)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return pv
+}
+
+// serve writes versions of a module to the proxy, replacing any versions it served before.
+func (p *moduleProxy) serve(path string, versions map[string]proxyVersion) {
+	t := p.t
+	versionDir := filepath.Join(p.dir, filepath.FromSlash(path), "@v")
+	require.NoError(t, os.MkdirAll(versionDir, 0755))
+
+	list := make([]string, 0, len(versions))
+	for v, pv := range versions {
+		list = append(list, v)
+		if pv.GoMod == "" {
+			pv.GoMod = fmt.Sprintf("module %s\n", path)
+		}
+		if pv.Info == "" {
+			pv.Info = fmt.Sprintf(`{"Version":%q}`, v)
+		}
+		require.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, v+".info"), []byte(pv.Info), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, v+".mod"), []byte(pv.GoMod), 0644))
+
+		files := map[string]string{"go.mod": pv.GoMod}
+		for name, content := range pv.Files {
+			files[name] = content
+		}
+		writeModuleZip(t, filepath.Join(versionDir, v+".zip"), path, v, files)
+	}
+	semver.Sort(list)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, "list"), []byte(strings.Join(list, "\n")+"\n"), 0644))
+}
+
+func writeModuleZip(t *testing.T, fn, path, version string, files map[string]string) {
+	f, err := os.Create(fn)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(path + "@" + version + "/" + name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+}
+
+// gitFixture copies a fixture to a git repository, with a single commit on branch and tags for that commit.
+// Returns the repository, and a function that runs git in it.
+func gitFixture(t *testing.T, fixture, branch string, tags ...string) (string, func(args ...string) string) {
+	tempDir := updatertest.TempDirFromFixture(t, fixture)
+	git := gitCommand(t, tempDir)
+	git("init", "-q", "-b", branch)
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+	for _, tag := range tags {
+		git("tag", tag)
+	}
+	return tempDir, git
+}
+
+// gitCommand returns a function that runs git in a directory, returning its output.
+func gitCommand(t *testing.T, dir string) func(args ...string) string {
+	return func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+}
+
+// writeFixtureFile writes a file of a fixture copied to a temporary directory, e.g. a strategy config.
+func writeFixtureFile(t *testing.T, tempDir, fn, content string) {
+	path := filepath.Join(tempDir, filepath.FromSlash(fn))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
@@ -0,0 +1,219 @@
+package gomodules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dependabot/gomodules-extracted/cmd/go/_internal_/modfetch"
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// checkForkReplacement proposes dropping the replacement of an upstream module by a fork, once upstream has
+// released a version containing the fork's commit. If the fork's commit is unknown or was never merged upstream,
+// the first upstream release newer than the version the fork is based on is proposed.
+// The update is to the upstream module; applying it drops the replacement by this fork, and is remembered so
+// ordinary updates of the upstream module leave fork replacements alone.
+func (u *Updater) checkForkReplacement(ctx context.Context, dep updater.Dependency, filter func(string) bool) (*updater.Update, error) {
+	upstream, err := u.forkUpstream(dep)
+	if err != nil || upstream == nil {
+		return nil, err
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"path":     upstream.Path,
+		"fork":     dep.Path,
+		"fork_ver": dep.Version,
+	})
+
+	nfo, err := u.queryModuleVersions(ctx, upstream.Path, filter)
+	if err != nil {
+		return nil, fmt.Errorf("querying upstream versions: %w", err)
+	} else if nfo == nil {
+		return nil, nil
+	}
+	base := forkBaseVersion(dep.Version)
+	candidates := make([]string, 0, len(nfo.Versions))
+	for _, v := range nfo.Versions {
+		if semver.Compare(upstream.Version, v) <= 0 && semver.Compare(base, v) < 0 {
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		log.Debug("no upstream release newer than fork")
+		return nil, nil
+	}
+
+	proposal := forkProposal{fork: module.Version{Path: dep.Path, Version: dep.Version}}
+	next := candidates[0]
+	if rev, err := u.forkRevision(ctx, dep); err != nil {
+		return nil, err
+	} else if rev != "" {
+		containing, err := u.newestReleaseContaining(ctx, upstream.Path, rev, candidates)
+		if err != nil {
+			return nil, err
+		} else if containing != "" {
+			next, proposal.released = containing, true
+		} else {
+			log.Debug("fork commit not released upstream, proposing release after fork base")
+		}
+	}
+
+	log.WithField("latest_version", next).Info("upstream release replaces fork")
+	update := updater.Update{
+		Path:     upstream.Path,
+		Previous: upstream.Version,
+		Next:     next,
+	}
+	if u.forkProposals == nil {
+		u.forkProposals = map[updater.Update]forkProposal{}
+	}
+	u.forkProposals[update] = proposal
+	return &update, nil
+}
+
+// forkProposal is a fork whose replacement is dropped by an update proposed by checkForkReplacement.
+type forkProposal struct {
+	fork module.Version
+	// released is true if the update's version contains the fork's commit
+	released bool
+}
+
+// verifyForkProposal checks the upstream release of an update still supersedes a fork, as it did when the update was proposed.
+func (u *Updater) verifyForkProposal(ctx context.Context, p forkProposal, update updater.Update) error {
+	if semver.Compare(forkBaseVersion(p.fork.Version), update.Next) >= 0 {
+		return fmt.Errorf("fork %s@%s is not older than %s", p.fork.Path, p.fork.Version, update.Next)
+	}
+	if !p.released {
+		return nil
+	}
+	rev, err := u.forkRevision(ctx, updater.Dependency{Path: p.fork.Path, Version: p.fork.Version})
+	if err != nil {
+		return err
+	}
+	containing, err := u.newestReleaseContaining(ctx, update.Path, rev, []string{update.Next})
+	if err != nil {
+		return err
+	} else if containing == "" {
+		return fmt.Errorf("fork %s@%s is not contained in %s", p.fork.Path, p.fork.Version, update.Next)
+	}
+	return nil
+}
+
+// forkUpstream returns the upstream requirement replaced by a dependency, or nil if the dependency replaces nothing.
+func (u *Updater) forkUpstream(dep updater.Dependency) (*module.Version, error) {
+	versions, err := u.requiredVersions(dep.Path)
+	if err != nil {
+		return nil, err
+	}
+	for gomod := range versions {
+		parsed, err := u.parseGoMod(gomod)
+		if err != nil {
+			return nil, err
+		}
+		if req, rep := activeReplacement(parsed, dep.Path); rep != nil && rep.New.Version == dep.Version && req.Mod.Path != dep.Path {
+			return &module.Version{Path: req.Mod.Path, Version: req.Mod.Version}, nil
+		}
+	}
+	return nil, nil
+}
+
+// forkRevision returns the commit of a fork's version, or "" if it is unknown.
+func (u *Updater) forkRevision(ctx context.Context, dep updater.Dependency) (string, error) {
+	if modfetch.IsPseudoVersion(dep.Version) {
+		return modfetch.PseudoVersionRev(dep.Version)
+	}
+	nfo, err := u.listModule(ctx, fmt.Sprintf("%s@%s", dep.Path, dep.Version))
+	if err != nil {
+		return "", fmt.Errorf("querying fork version: %w", err)
+	}
+	if nfo.Origin == nil || nfo.Origin.VCS != "git" {
+		return "", nil
+	}
+	return nfo.Origin.Hash, nil
+}
+
+// forkBaseVersion returns the release a fork's version is based on, e.g. v1.2.3 for v1.2.3-backport.1 or v1.2.4-0.20200101000000-abcdef123456.
+func forkBaseVersion(version string) string {
+	if modfetch.IsPseudoVersion(version) {
+		base, _ := modfetch.PseudoVersionBase(version)
+		return base
+	}
+	v := semver.Canonical(version)
+	return strings.TrimSuffix(v, semver.Prerelease(v))
+}
+
+// forkReplacement returns the replacement of a required path by a version of another module, e.g. a fork.
+// Returns nil if the path is not required, or is not replaced by a fork.
+func forkReplacement(goMod *modfile.File, path string) *modfile.Replace {
+	for _, req := range goMod.Require {
+		if req.Mod.Path != path {
+			continue
+		}
+		if rep := findReplacement(req.Mod.Path, req.Mod.Version, goMod.Replace); rep != nil && rep.New.Path != path && rep.New.Version != "" {
+			return rep
+		}
+	}
+	return nil
+}
+
+// forkReplacingGoModFiles returns the go.mod files whose fork replacement is dropped by an update.
+// Only updates proposed by checkForkReplacement drop a replacement, and only by the fork they were proposed for.
+func (u *Updater) forkReplacingGoModFiles(ctx context.Context, update updater.Update) ([]string, error) {
+	proposal, ok := u.forkProposals[update]
+	if !ok {
+		return nil, nil
+	}
+
+	goModFiles, err := u.collectGoModFiles()
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, gomod := range goModFiles {
+		parsed, err := u.parseGoMod(gomod)
+		if err != nil {
+			return nil, err
+		}
+		if u.proposedForkReplacement(parsed, update) != nil {
+			ret = append(ret, gomod)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, nil
+	}
+	if err := u.verifyForkProposal(ctx, proposal, update); err != nil {
+		return nil, fmt.Errorf("fork replacement changed since check: %w", err)
+	}
+	return ret, nil
+}
+
+// proposedForkReplacement returns the replacement of an update's path by the fork the update was proposed for, or nil.
+func (u *Updater) proposedForkReplacement(goMod *modfile.File, update updater.Update) *modfile.Replace {
+	proposal, ok := u.forkProposals[update]
+	if !ok {
+		return nil
+	}
+	if rep := forkReplacement(goMod, update.Path); rep != nil && rep.New.Path == proposal.fork.Path && rep.New.Version == proposal.fork.Version {
+		return rep
+	}
+	return nil
+}
+
+// dropForkReplacement removes the replacement of an upstream module by a fork, and requires the upstream module.
+func dropForkReplacement(goMod *modfile.File, update updater.Update, rep *modfile.Replace) error {
+	logrus.WithFields(logrus.Fields{
+		"path": update.Path,
+		"fork": rep.New.Path,
+	}).Debug("dropping fork replacement")
+	if err := goMod.DropReplace(rep.Old.Path, rep.Old.Version); err != nil {
+		return fmt.Errorf("dropping replacement: %w", err)
+	}
+	if err := goMod.AddRequire(update.Path, update.Next); err != nil {
+		return fmt.Errorf("adding requirement: %w", err)
+	}
+	return nil
+}
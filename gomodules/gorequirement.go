@@ -0,0 +1,215 @@
+package gomodules
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// GoVersionPolicy controls updates to versions that declare a newer Go version than the go.mod files requiring them.
+type GoVersionPolicy string
+
+const (
+	// GoVersionIgnore proposes updates regardless of the Go version they declare, `go get` may bump the go directive
+	GoVersionIgnore GoVersionPolicy = "ignore"
+	// GoVersionSkip does not propose updates that declare a newer Go version
+	GoVersionSkip GoVersionPolicy = "skip"
+	// GoVersionCompatible proposes the newest version that does not declare a newer Go version
+	GoVersionCompatible GoVersionPolicy = "compatible"
+	// GoVersionBump proposes updates that declare a newer Go version, and explicitly bumps the go directive to match
+	GoVersionBump GoVersionPolicy = "bump"
+)
+
+// checkGoRequirement applies the GoVersionPolicy to an update.
+func (u *Updater) checkGoRequirement(ctx context.Context, dep updater.Dependency, update *updater.Update, filter func(string) bool) (*updater.Update, error) {
+	switch u.GoVersionPolicy {
+	case GoVersionSkip, GoVersionCompatible, GoVersionBump:
+	default:
+		return update, nil
+	}
+	if update == nil {
+		return nil, nil
+	}
+
+	ours, required, err := u.goRequirement(ctx, dep.Path, *update)
+	if err != nil {
+		return nil, err
+	} else if required == "" {
+		return update, nil
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"path":         dep.Path,
+		"next":         update.Next,
+		"go_version":   ours,
+		"go_directive": required,
+	})
+	switch u.GoVersionPolicy {
+	case GoVersionSkip:
+		log.Info("skipping update that requires a newer go version")
+		return nil, nil
+	case GoVersionBump:
+		log.Info("update requires a newer go version, the go directive will be bumped")
+		return update, nil
+	}
+
+	// Search for the newest compatible version, falling back through each major version to the current one:
+	log.Info("update requires a newer go version, searching for a compatible version")
+	for _, path := range majorFallbackPaths(dep, *update) {
+		excluded, err := u.excludedVersions(dep.Path, path)
+		if err != nil {
+			return nil, fmt.Errorf("collecting excluded versions: %w", err)
+		}
+		next, err := u.newestCompatibleVersion(ctx, path, "", ours, excludeFilter(filter, excluded))
+		if err != nil {
+			if strings.Contains(err.Error(), "exit status 1") {
+				// Assume we queried for a major version that doesn't exist
+				continue
+			}
+			return nil, err
+		} else if next != "" {
+			return &updater.Update{Path: dep.Path, Previous: dep.Version, Next: next}, nil
+		}
+	}
+	next, err := u.newestCompatibleVersion(ctx, dep.Path, dep.Version, ours, filter)
+	if err != nil || next == "" {
+		return nil, err
+	}
+	return &updater.Update{Path: dep.Path, Previous: dep.Version, Next: next}, nil
+}
+
+// goRequirement returns the oldest go directive of the go.mod files that require a path, and the go directive
+// declared by an update of the path if it is newer.
+func (u *Updater) goRequirement(ctx context.Context, path string, update updater.Update) (ours, required string, err error) {
+	ours, err = u.requiredGoVersion(path)
+	if err != nil || ours == "" {
+		return "", "", err
+	}
+	required, err = u.moduleGoVersion(ctx, updateModulePath(update), update.Next)
+	if err != nil {
+		return "", "", err
+	}
+	if !newerGoVersion(required, ours) {
+		return ours, "", nil
+	}
+	return ours, required, nil
+}
+
+// majorFallbackPaths returns the module paths of a major update's version, and each major version between it and
+// the dependency's, newest first. e.g. foo/v3 and foo/v2 for foo v1.2.0 -> v3.0.0.
+func majorFallbackPaths(dep updater.Dependency, update updater.Update) []string {
+	if !MajorPkg(update) {
+		return nil
+	}
+	next, _ := strconv.Atoi(strings.TrimPrefix(semver.Major(update.Next), "v"))
+	current, _ := strconv.Atoi(strings.TrimPrefix(semver.Major(dep.Version), "v"))
+	var paths []string
+	for major := next; major > current && major >= 2; major-- {
+		paths = append(paths, pathMajorVersion(dep.Path, fmt.Sprintf("v%d", major)))
+	}
+	return paths
+}
+
+// newestCompatibleVersion returns the newest version of path, newer than current, that does not declare a newer Go version.
+func (u *Updater) newestCompatibleVersion(ctx context.Context, path, current, goVersion string, filter func(string) bool) (string, error) {
+	nfo, err := u.queryModuleVersions(ctx, path, filter)
+	if err != nil || nfo == nil {
+		return "", err
+	}
+	for i := len(nfo.Versions) - 1; i >= 0; i-- {
+		v := nfo.Versions[i]
+		if current != "" && semver.Compare(v, current) <= 0 {
+			break
+		}
+		required, err := u.moduleGoVersion(ctx, path, v)
+		if err != nil {
+			return "", err
+		}
+		if !newerGoVersion(required, goVersion) {
+			return v, nil
+		}
+	}
+	return "", nil
+}
+
+// requiredGoVersion returns the oldest go directive of the go.mod files that require a path.
+func (u *Updater) requiredGoVersion(path string) (string, error) {
+	versions, err := u.requiredVersions(path)
+	if err != nil {
+		return "", err
+	}
+	var oldest string
+	for gomod := range versions {
+		parsed, err := u.parseGoMod(gomod)
+		if err != nil {
+			return "", err
+		}
+		if parsed.Go == nil {
+			continue
+		}
+		if oldest == "" || newerGoVersion(oldest, parsed.Go.Version) {
+			oldest = parsed.Go.Version
+		}
+	}
+	return oldest, nil
+}
+
+// moduleGoVersion returns the go directive of a module version's go.mod file.
+func (u *Updater) moduleGoVersion(ctx context.Context, path, version string) (string, error) {
+	nfo, err := u.listModule(ctx, fmt.Sprintf("%s@%s", path, version))
+	if err != nil {
+		return "", fmt.Errorf("querying go version of %s@%s: %w", path, version, err)
+	}
+	return nfo.GoVersion, nil
+}
+
+// updateModulePath returns the module path of an update's next version, e.g. github.com/foo/bar/v2.
+func updateModulePath(update updater.Update) string {
+	if MajorPkg(update) {
+		return pathMajorVersion(update.Path, semver.Major(update.Next))
+	}
+	return update.Path
+}
+
+// newerGoVersion returns true if Go version a is newer than b, e.g. "1.22" is newer than "1.21.5".
+func newerGoVersion(a, b string) bool {
+	sa, sb := semverGoVersion(a), semverGoVersion(b)
+	if sa == "" || sb == "" {
+		return false
+	}
+	return semver.Compare(sa, sb) > 0
+}
+
+// bumpGoDirective raises the go directive of a go.mod file to the Go version declared by an update.
+func (u *Updater) bumpGoDirective(ctx context.Context, goMod *modfile.File, update updater.Update) error {
+	if goMod.Go == nil {
+		return nil
+	}
+	required, err := u.moduleGoVersion(ctx, updateModulePath(update), update.Next)
+	if err != nil {
+		return err
+	}
+	if !newerGoVersion(required, goMod.Go.Version) {
+		return nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"path":     update.Path,
+		"previous": goMod.Go.Version,
+		"next":     required,
+	}).Info("bumping go directive")
+	if err := goMod.AddGoStmt(required); err != nil {
+		return fmt.Errorf("updating go directive: %w", err)
+	}
+	if goMod.Toolchain != nil && newerGoVersion(required, strings.TrimPrefix(goMod.Toolchain.Name, "go")) {
+		// The toolchain must be at least the go directive:
+		goMod.DropToolchainStmt()
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package gomodules
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// GoVersionPath is the path of the dependency on Go itself, versioned like a module e.g. "v1.21.5".
+const GoVersionPath = "go"
+
+// ToolchainModule is the module that distributes Go releases through GOPROXY.
+const ToolchainModule = "golang.org/toolchain"
+
+// goVersionDependency returns the dependency on Go, at the oldest version required by a go.mod file.
+func (u *Updater) goVersionDependency(gomods []string) (*requirement, error) {
+	req := &requirement{Dependency: updater.Dependency{Path: GoVersionPath}}
+	for _, gomod := range gomods {
+		parsed, err := u.parseGoMod(gomod)
+		if err != nil {
+			return nil, err
+		}
+		v := goModVersion(parsed)
+		if v == "" {
+			continue
+		}
+		if req.Version == "" || semver.Compare(v, req.Version) < 0 {
+			req.Version = v
+		}
+		req.GoMods = append(req.GoMods, gomod)
+	}
+	if req.Version == "" {
+		return nil, nil
+	}
+	return req, nil
+}
+
+// goModVersion returns the Go version used by a go.mod file: the toolchain directive, or the go directive.
+func goModVersion(parsed *modfile.File) string {
+	if parsed.Toolchain != nil {
+		if v := semverGoVersion(strings.TrimPrefix(parsed.Toolchain.Name, "go")); v != "" {
+			return v
+		}
+	}
+	if parsed.Go != nil {
+		return semverGoVersion(parsed.Go.Version)
+	}
+	return ""
+}
+
+// semverGoVersion converts a Go version to semver, e.g. "1.21.5" to "v1.21.5".
+// Returns "" for prereleases, e.g. "1.22rc1", which are not valid semver.
+func semverGoVersion(v string) string {
+	if sv := "v" + v; semver.IsValid(sv) {
+		return sv
+	}
+	return ""
+}
+
+// checkGoVersion proposes the newest Go release, as distributed by ToolchainModule.
+func (u *Updater) checkGoVersion(ctx context.Context, dep updater.Dependency, filter func(string) bool) (*updater.Update, error) {
+	log := logrus.WithField("path", dep.Path)
+	log.Debug("querying latest go version")
+
+	nfo, err := u.listModule(ctx, "-versions", ToolchainModule)
+	if err != nil {
+		return nil, fmt.Errorf("querying go versions: %w", err)
+	}
+
+	var latest string
+	for _, v := range nfo.Versions {
+		// Toolchain versions are e.g. v0.0.1-go1.21.5.linux-amd64:
+		i := strings.Index(v, "-go")
+		if i < 0 {
+			continue
+		}
+		goVersion := v[i+len("-go"):]
+		if dot := strings.LastIndex(goVersion, "."); dot >= 0 {
+			goVersion = goVersion[:dot]
+		}
+		sv := semverGoVersion(goVersion)
+		if sv == "" || semver.Compare(sv, latest) <= 0 || (filter != nil && !filter(sv)) {
+			continue
+		}
+		latest = sv
+	}
+	if semver.Compare(latest, dep.Version) <= 0 {
+		return nil, nil
+	}
+
+	log.WithFields(logrus.Fields{
+		"latest_version":  latest,
+		"current_version": dep.Version,
+	}).Info("go update available")
+	return &updater.Update{
+		Path:     GoVersionPath,
+		Previous: dep.Version,
+		Next:     latest,
+	}, nil
+}
+
+// applyGoVersion moves go.mod files, Dockerfiles and workflows to the next Go version.
+func (u *Updater) applyGoVersion(update updater.Update) error {
+	goModFiles, err := u.collectGoModFiles()
+	if err != nil {
+		return fmt.Errorf("collecting go.mod files: %w", err)
+	}
+	for _, gomod := range goModFiles {
+		if err := u.updateGoModGoVersion(gomod, update); err != nil {
+			return err
+		}
+	}
+
+	files, err := u.goVersionFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		changed, err := updateGoVersionReferences(f, update.Next)
+		if err != nil {
+			return err
+		}
+		if changed {
+			logrus.WithField("file_path", f).Info("updated go version")
+		}
+	}
+	return nil
+}
+
+// updateGoModGoVersion moves the toolchain directive of a go.mod file to the next Go version.
+// The go directive is the minimum version for users of the module, so it is only raised to the next minor version.
+func (u *Updater) updateGoModGoVersion(path string, update updater.Update) error {
+	goMod, err := u.parseGoMod(path)
+	if err != nil {
+		return err
+	}
+	if goMod.Go == nil || semver.Compare(goModVersion(goMod), update.Next) >= 0 {
+		return nil
+	}
+
+	nextMinor := semver.MajorMinor(update.Next)
+	if semver.Compare(semverGoVersion(goMod.Go.Version), nextMinor) < 0 {
+		if err := goMod.AddGoStmt(formatGoVersion(nextMinor, goMod.Go.Version)); err != nil {
+			return fmt.Errorf("updating go directive: %w", err)
+		}
+	}
+	// Toolchain directives are supported since Go 1.21, and are redundant if the go directive matches:
+	if goMod.Toolchain != nil || (semver.Compare(update.Next, "v1.21") >= 0 && semver.Compare(semverGoVersion(goMod.Go.Version), update.Next) < 0) {
+		if err := goMod.AddToolchainStmt("go" + formatGoVersion(update.Next, "")); err != nil {
+			return fmt.Errorf("updating toolchain directive: %w", err)
+		}
+	}
+	updated, err := goMod.Format()
+	if err != nil {
+		return fmt.Errorf("formatting go.mod: %w", err)
+	}
+	if err := ioutil.WriteFile(path, updated, 0644); err != nil {
+		return fmt.Errorf("writing updated go.mod: %w", err)
+	}
+	return nil
+}
+
+// formatGoVersion formats a semver Go version with the precision of a previous version, e.g. "1.18" has no patch.
+func formatGoVersion(next, previous string) string {
+	if previous != "" && strings.Count(previous, ".") == 1 {
+		return strings.TrimPrefix(semver.MajorMinor(next), "v")
+	}
+	return strings.TrimPrefix(semver.Canonical(next), "v")
+}
+
+// goVersionFiles returns the Dockerfiles and GitHub workflows that may reference a Go version.
+func (u *Updater) goVersionFiles() ([]string, error) {
+	return u.repoFiles(isDockerfile)
+}
+
+func isDockerfile(name string) bool {
+	return name == "Dockerfile" || strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".Dockerfile")
+}
+
+// repoFiles returns files in the repository whose name matches, and every GitHub workflow.
+func (u *Updater) repoFiles(match func(name string) bool) ([]string, error) {
+	var files []string
+	err := filepath.Walk(u.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != u.root && u.skipDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if match(info.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collecting files: %w", err)
+	}
+
+	workflows, err := filepath.Glob(filepath.Join(u.root, ".github", "workflows", "*.y*ml"))
+	if err != nil {
+		return nil, fmt.Errorf("collecting workflows: %w", err)
+	}
+	return append(files, workflows...), nil
+}
+
+var goVersionReferenceREs = []*regexp.Regexp{
+	// e.g. FROM golang:1.18.10-alpine AS builder
+	regexp.MustCompile(`(?m)^(\s*FROM\s+(?:--platform=\S+\s+)?(?:\S+/)?golang:)(\d+\.\d+(?:\.\d+)?)\b`),
+	// e.g. go-version: '1.18.10'
+	regexp.MustCompile(`(?m)^(\s*(?:-\s+)?go-version:\s*['"]?)(\d+\.\d+(?:\.\d+)?)(['"]?[ \t]*(?:#.*)?)$`),
+}
+
+// updateGoVersionReferences rewrites Go versions in a file to the next version, keeping their precision.
+func updateGoVersionReferences(path, next string) (bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("reading file: %w", err)
+	}
+
+	updated := string(b)
+	for _, re := range goVersionReferenceREs {
+		updated = re.ReplaceAllStringFunc(updated, func(m string) string {
+			sub := re.FindStringSubmatch(m)
+			if semver.Compare(semverGoVersion(sub[2]), next) >= 0 {
+				return m
+			}
+			return sub[1] + formatGoVersion(next, sub[2]) + strings.Join(sub[3:], "")
+		})
+	}
+	if updated == string(b) {
+		return false, nil
+	}
+	return true, writeFilePreservingMode(path, []byte(updated))
+}
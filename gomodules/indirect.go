@@ -0,0 +1,160 @@
+package gomodules
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+)
+
+// IndirectPolicy controls updates of dependencies that are only required indirectly, i.e. `// indirect`.
+type IndirectPolicy string
+
+const (
+	// IndirectInclude updates indirect dependencies like any other
+	IndirectInclude IndirectPolicy = "include"
+	// IndirectSkip never updates indirect dependencies
+	IndirectSkip IndirectPolicy = "skip"
+	// IndirectBatch updates indirect dependencies together, as a single IndirectBatchPath dependency
+	IndirectBatch IndirectPolicy = "batch"
+	// IndirectShared updates indirect dependencies only if another module in the repository requires them directly
+	IndirectShared IndirectPolicy = "shared"
+)
+
+// IndirectBatchPath is the path of the dependency that batches indirect dependencies.
+// Its version is a digest of the batched modules, so it changes whenever they do.
+const IndirectBatchPath = "indirect"
+
+// filterIndirect applies the indirect dependency policy to dependencies.
+func (u *Updater) filterIndirect(deps map[string]*requirement) map[string]*requirement {
+	switch u.IndirectPolicy {
+	case IndirectSkip, IndirectBatch, IndirectShared:
+	default:
+		return deps
+	}
+
+	direct := map[string]bool{}
+	for _, req := range deps {
+		if !req.Indirect {
+			direct[req.Path] = true
+		}
+	}
+
+	filtered := make(map[string]*requirement, len(deps))
+	var batched []string
+	for key, req := range deps {
+		if req.Indirect && !(u.IndirectPolicy == IndirectShared && direct[req.Path]) {
+			batched = append(batched, fmt.Sprintf("%s@%s", req.Path, req.Version))
+			continue
+		}
+		filtered[key] = req
+	}
+	if u.IndirectPolicy == IndirectBatch && len(batched) > 0 {
+		filtered[IndirectBatchPath] = &requirement{
+			Dependency: updater.Dependency{Path: IndirectBatchPath, Version: versionDigest(batched)},
+		}
+	}
+	return filtered
+}
+
+// checkIndirectBatch proposes updating every indirect dependency that has an update available.
+func (u *Updater) checkIndirectBatch(ctx context.Context, dep updater.Dependency) (*updater.Update, error) {
+	updates, err := u.indirectUpdates(ctx)
+	if err != nil || len(updates) == 0 {
+		return nil, err
+	}
+
+	next := indirectUpdatesDigest(updates)
+	modules := make([]string, 0, len(updates))
+	for _, up := range updates {
+		modules = append(modules, fmt.Sprintf("%s@%s", up.Path, up.Next))
+	}
+	logrus.WithFields(logrus.Fields{
+		"next":    next,
+		"modules": modules,
+	}).Info("indirect dependency updates available")
+	return &updater.Update{
+		Path:     IndirectBatchPath,
+		Previous: dep.Version,
+		Next:     next,
+	}, nil
+}
+
+// applyIndirectBatch applies the updates batched by checkIndirectBatch.
+func (u *Updater) applyIndirectBatch(ctx context.Context, update updater.Update) error {
+	updates, err := u.indirectUpdates(ctx)
+	if err != nil {
+		return err
+	}
+	// The batch must match what was proposed, or the branch would not contain the updates it is named for:
+	if digest := indirectUpdatesDigest(updates); digest != update.Next {
+		return fmt.Errorf("indirect dependency updates changed since check: expected %s, got %s", update.Next, digest)
+	}
+
+	for _, up := range updates {
+		logrus.WithFields(logrus.Fields{
+			"path": up.Path,
+			"next": up.Next,
+		}).Debug("applying indirect dependency update")
+		if err := u.ApplyUpdate(ctx, up); err != nil {
+			return fmt.Errorf("updating indirect dependency %s: %w", up.Path, err)
+		}
+	}
+	return nil
+}
+
+// indirectUpdates returns the available updates of indirect dependencies, sorted by path.
+func (u *Updater) indirectUpdates(ctx context.Context) ([]updater.Update, error) {
+	goModFiles, err := u.collectGoModFiles()
+	if err != nil {
+		return nil, err
+	}
+	deps, err := u.collectUniqueDependencies(goModFiles)
+	if err != nil {
+		return nil, err
+	}
+	if u.AlignVersions {
+		deps = alignDependencies(deps)
+	}
+	sorted, err := sortUniqueDependencies(deps)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []updater.Update
+	for _, dep := range sorted {
+		if !dep.Indirect {
+			continue
+		}
+		up, err := u.Check(ctx, dep, nil)
+		if err != nil {
+			logrus.WithError(err).WithField("path", dep.Path).Warn("error checking indirect dependency")
+			continue
+		}
+		if up != nil {
+			updates = append(updates, *up)
+		}
+	}
+	return updates, nil
+}
+
+func indirectUpdatesDigest(updates []updater.Update) string {
+	versions := make([]string, 0, len(updates))
+	for _, up := range updates {
+		versions = append(versions, fmt.Sprintf("%s@%s", up.Path, up.Next))
+	}
+	return versionDigest(versions)
+}
+
+// versionDigest returns a short digest identifying a set of module versions.
+func versionDigest(versions []string) string {
+	sorted := append([]string(nil), versions...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:])[:12]
+}
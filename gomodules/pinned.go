@@ -0,0 +1,214 @@
+package gomodules
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/semver"
+)
+
+// pinnedReferenceRE matches tools pinned by `go install` and `go run`, e.g. `go install golang.org/x/tools/cmd/stringer@v0.1.0`
+var pinnedReferenceRE = regexp.MustCompile(`\bgo\s+(?:install|run)\s+(?:-\S+\s+)*([\w.~-]+(?:/[\w.~+-]+)+)@(v\d[\w.+-]*)`)
+
+// pinnedReference is a package pinned to a version, within a file.
+type pinnedReference struct {
+	pkg     string
+	version string
+}
+
+// isPinnedReferenceFile returns true for files that may pin tools. Workflows are always searched.
+func isPinnedReferenceFile(name string) bool {
+	switch {
+	case name == "Makefile", name == "GNUmakefile", isDockerfile(name):
+		return true
+	}
+	switch filepath.Ext(name) {
+	case ".go", ".mk", ".sh", ".bash":
+		return true
+	}
+	return false
+}
+
+// pinnedReferences returns tools pinned by `go install` and `go run` in files of the repository, by file.
+// In Go files, only `//go:generate` directives are searched.
+func (u *Updater) pinnedReferences() (map[string][]pinnedReference, error) {
+	files, err := u.repoFiles(isPinnedReferenceFile)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := map[string][]pinnedReference{}
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening file: %w", err)
+		}
+		goFile := filepath.Ext(path) == ".go"
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if goFile && !strings.HasPrefix(line, "//go:generate ") {
+				continue
+			}
+			for _, m := range pinnedReferenceRE.FindAllStringSubmatch(line, -1) {
+				if semver.IsValid(m[2]) {
+					refs[path] = append(refs[path], pinnedReference{pkg: m[1], version: m[2]})
+				}
+			}
+		}
+		err = scanner.Err()
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading file: %w", err)
+		}
+	}
+	return refs, nil
+}
+
+// pinnedDependencies returns the modules of tools pinned by `go install` and `go run`.
+func (u *Updater) pinnedDependencies(ctx context.Context) ([]updater.Dependency, error) {
+	refs, err := u.pinnedReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[pinnedReference]bool{}
+	var deps []updater.Dependency
+	for _, fileRefs := range refs {
+		for _, ref := range fileRefs {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			mod, err := u.pinnedModule(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			if mod == "" {
+				logrus.WithField("package", ref.pkg).Warn("module of pinned package not found")
+				continue
+			}
+			deps = append(deps, updater.Dependency{Path: mod, Version: ref.version})
+		}
+	}
+	return deps, nil
+}
+
+// pinnedModule resolves the module providing a pinned package, by querying successively shorter prefixes of the package path.
+// Resolutions are kept, so references found by Dependencies are not queried again when updates are applied.
+func (u *Updater) pinnedModule(ctx context.Context, ref pinnedReference) (string, error) {
+	if mod, ok := u.pinnedModules[ref]; ok {
+		return mod, nil
+	}
+
+	var mod string
+	for path := ref.pkg; strings.Contains(path, "/"); path = path[:strings.LastIndex(path, "/")] {
+		ok, err := u.moduleVersionExists(ctx, path, ref.version)
+		if err != nil {
+			return "", err
+		} else if ok {
+			mod = path
+			break
+		}
+	}
+	if u.pinnedModules == nil {
+		u.pinnedModules = map[pinnedReference]string{}
+	}
+	u.pinnedModules[ref] = mod
+	return mod, nil
+}
+
+// moduleNotFoundRE matches `go` errors for module versions that don't exist, rather than e.g. authentication failures.
+var moduleNotFoundRE = regexp.MustCompile(`404 Not Found|410 Gone|no such file or directory|unknown revision|invalid version|no matching versions|malformed module path|repository '.*' not found`)
+
+// moduleVersionExists returns true if a module is available at a version.
+func (u *Updater) moduleVersionExists(ctx context.Context, path, version string) (bool, error) {
+	_, errBuf, err := runInScratchModule(ctx, "list", "-m", "-mod=mod", "-json", fmt.Sprintf("%s@%s", path, version))
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if stderr := errBuf.String(); errors.As(err, &exitErr) && moduleNotFoundRE.MatchString(stderr) {
+		logrus.WithFields(logrus.Fields{
+			"path":   path,
+			"stderr": stderr,
+		}).Debug("module version not found")
+		return false, nil
+	}
+	return false, fmt.Errorf("querying module %s@%s: %w: %s", path, version, err, strings.TrimSpace(errBuf.String()))
+}
+
+// updatePinnedReferences rewrites tools pinned to older versions of the updated module.
+func (u *Updater) updatePinnedReferences(ctx context.Context, update updater.Update) error {
+	refs, err := u.pinnedReferences()
+	if err != nil {
+		return err
+	}
+	newPath := updateModulePath(update)
+
+	for path, fileRefs := range refs {
+		replacements := map[string]string{}
+		for _, ref := range fileRefs {
+			if semver.Compare(ref.version, update.Next) >= 0 {
+				continue
+			}
+			pkg, ok := rewriteImportPath(ref.pkg, update.Path, newPath)
+			if !ok {
+				continue
+			}
+			// The package may be within a nested module, that shares a prefix with the updated module:
+			if mod, err := u.pinnedModule(ctx, ref); err != nil {
+				return err
+			} else if mod != update.Path {
+				continue
+			}
+			replacements[ref.pkg+"@"+ref.version] = pkg + "@" + update.Next
+		}
+		if len(replacements) == 0 {
+			continue
+		}
+
+		if err := rewritePinnedReferences(path, replacements); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"file_path": path,
+			"path":      update.Path,
+			"next":      update.Next,
+		}).Info("updated pinned references")
+	}
+	return nil
+}
+
+func rewritePinnedReferences(path string, replacements map[string]string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+
+	goFile := filepath.Ext(path) == ".go"
+	lines := strings.SplitAfter(string(b), "\n")
+	for i, line := range lines {
+		if goFile && !strings.HasPrefix(line, "//go:generate ") {
+			continue
+		}
+		lines[i] = pinnedReferenceRE.ReplaceAllStringFunc(line, func(m string) string {
+			sub := pinnedReferenceRE.FindStringSubmatchIndex(m)
+			if replacement, ok := replacements[m[sub[2]:sub[5]]]; ok {
+				return m[:sub[2]] + replacement + m[sub[5]:]
+			}
+			return m
+		})
+	}
+	return writeFilePreservingMode(path, []byte(strings.Join(lines, "")))
+}
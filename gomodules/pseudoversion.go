@@ -0,0 +1,164 @@
+package gomodules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dependabot/gomodules-extracted/cmd/go/_internal_/modfetch"
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/semver"
+)
+
+// defaultBranchQuery resolves the default branch of a module's repository.
+const defaultBranchQuery = "HEAD"
+
+// checkForPseudoVersionUpdate proposes the latest commit of a branch, for a dependency pinned to a pseudo-version.
+func (u *Updater) checkForPseudoVersionUpdate(ctx context.Context, dep updater.Dependency, filter func(string) bool) (*updater.Update, error) {
+	branch := u.PseudoVersionBranch
+	if branch == "" {
+		branch = defaultBranchQuery
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"path":            dep.Path,
+		"current_version": dep.Version,
+		"branch":          branch,
+	})
+	log.Debug("querying latest commit")
+
+	nfo, err := u.listModule(ctx, fmt.Sprintf("%s@%s", dep.Path, branch))
+	if err != nil {
+		return nil, fmt.Errorf("querying latest commit: %w", err)
+	}
+	latestVersion := nfo.Version
+	log = log.WithField("latest_version", latestVersion)
+	if semver.Compare(dep.Version, latestVersion) >= 0 || (filter != nil && !filter(latestVersion)) {
+		log.Debug("no update available")
+		return nil, nil
+	}
+
+	currentTime, err := modfetch.PseudoVersionTime(dep.Version)
+	if err != nil {
+		return nil, fmt.Errorf("parsing pseudoversion: %w", err)
+	}
+	latestTime, err := commitTime(nfo.Time, latestVersion)
+	if err != nil {
+		return nil, err
+	}
+	if !latestTime.After(currentTime) {
+		log.Debug("no newer commit available")
+		return nil, nil
+	}
+	if age := time.Since(latestTime); age < u.PseudoVersionMinAge {
+		log.WithField("age", age.Round(time.Minute)).Info("latest commit is too recent")
+		return nil, nil
+	}
+
+	log.Info("pseudoversion update available")
+	return &updater.Update{
+		Path:     dep.Path,
+		Previous: dep.Version,
+		Next:     latestVersion,
+	}, nil
+}
+
+// commitTime returns the time a version was committed, preferring the time reported by `go list`.
+func commitTime(listed *time.Time, version string) (time.Time, error) {
+	if listed != nil {
+		return *listed, nil
+	}
+	t, err := modfetch.PseudoVersionTime(version)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("version %s has no commit time: %w", version, err)
+	}
+	return t, nil
+}
+
+// checkForPseudoVersionRelease proposes the newest tagged release that contains the commit of a pseudo-version.
+func (u *Updater) checkForPseudoVersionRelease(ctx context.Context, dep updater.Dependency, filter func(string) bool) (*updater.Update, error) {
+	rev, err := modfetch.PseudoVersionRev(dep.Version)
+	if err != nil {
+		return nil, fmt.Errorf("parsing pseudoversion: %w", err)
+	}
+
+	nfo, err := u.queryModuleVersions(ctx, dep.Path, filter)
+	if err != nil {
+		return nil, err
+	} else if nfo == nil {
+		return nil, nil
+	}
+
+	candidates := make([]string, 0, len(nfo.Versions))
+	for _, v := range nfo.Versions {
+		if semver.Compare(dep.Version, v) < 0 && semver.Major(dep.Version) == semver.Major(v) {
+			candidates = append(candidates, v)
+		}
+	}
+	release, err := u.newestReleaseContaining(ctx, dep.Path, rev, candidates)
+	if err != nil || release == "" {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"path":            dep.Path,
+		"current_version": dep.Version,
+		"latest_version":  release,
+	}).Info("release containing pseudoversion available")
+	return &updater.Update{
+		Path:     dep.Path,
+		Previous: dep.Version,
+		Next:     release,
+	}, nil
+}
+
+// newestReleaseContaining returns the newest of a module's versions that contains a commit, or "" if none do.
+// Releases are verified against the module's VCS repository, as reported by the version's origin.
+func (u *Updater) newestReleaseContaining(ctx context.Context, path, rev string, versions []string) (string, error) {
+	log := logrus.WithFields(logrus.Fields{
+		"path": path,
+		"rev":  rev,
+	})
+
+	var repo *releaseRepo
+	defer func() {
+		if repo != nil {
+			repo.Close()
+		}
+	}()
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		release, err := u.listModule(ctx, fmt.Sprintf("%s@%s", path, v))
+		if err != nil {
+			return "", fmt.Errorf("querying release: %w", err)
+		}
+		origin := release.Origin
+		if origin == nil || origin.VCS != "git" || origin.Hash == "" {
+			log.WithField("version", v).Debug("release has no git origin, skipping")
+			continue
+		}
+
+		contained := strings.HasPrefix(origin.Hash, rev)
+		if !contained {
+			if repo == nil {
+				if repo, err = cloneReleaseRepo(ctx, origin.URL); err != nil {
+					return "", err
+				}
+				if !repo.HasCommit(ctx, rev) {
+					log.Info("commit not found in repository")
+					return "", nil
+				}
+			}
+			if contained, err = repo.IsAncestor(ctx, rev, origin.Hash); err != nil {
+				return "", err
+			}
+		}
+		if contained {
+			return v, nil
+		}
+	}
+
+	log.Debug("no release contains commit")
+	return "", nil
+}
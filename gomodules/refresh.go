@@ -0,0 +1,311 @@
+package gomodules
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dependabot/gomodules-extracted/cmd/go/_internal_/modinfo"
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/cmd"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/semver"
+)
+
+// RefreshMode selects how far a refresh of the module graph moves each module, like `go get -u`.
+type RefreshMode string
+
+const (
+	// RefreshPatch moves every module in the graph to its latest patch release, like `go get -u=patch all`
+	RefreshPatch RefreshMode = "patch"
+	// RefreshMinor moves every module in the graph to its latest minor release, like `go get -u all`
+	RefreshMinor RefreshMode = "minor"
+)
+
+// RefreshBatchPath is the path of the dependency that refreshes the entire module graph.
+// Its version is a digest of the required module versions, so it changes whenever they do.
+const RefreshBatchPath = "refresh"
+
+// refreshDependency returns the dependency that refreshes the module graph.
+func refreshDependency(deps map[string]*requirement) *requirement {
+	versions := make([]string, 0, len(deps))
+	for _, req := range deps {
+		versions = append(versions, fmt.Sprintf("%s@%s", req.Path, req.Version))
+	}
+	return &requirement{
+		Dependency: updater.Dependency{Path: RefreshBatchPath, Version: versionDigest(versions)},
+	}
+}
+
+// checkRefresh proposes refreshing the module graph if any module would move.
+func (u *Updater) checkRefresh(ctx context.Context, dep updater.Dependency) (*updater.Update, error) {
+	targets, moved, err := u.refreshTargets(ctx)
+	if err != nil {
+		return nil, err
+	} else if len(moved) == 0 {
+		return nil, nil
+	}
+
+	next := refreshDigest(targets)
+	logrus.WithFields(logrus.Fields{
+		"next":    next,
+		"modules": moved,
+	}).Info("module graph refresh available")
+	return &updater.Update{
+		Path:     RefreshBatchPath,
+		Previous: dep.Version,
+		Next:     next,
+	}, nil
+}
+
+// refreshTargets returns the `go get` arguments that refresh the module graph of each go.mod file, and summarizes the
+// modules that move. Modules pinned by a strategy and skipped tools do not move, and strategies can narrow the refresh.
+func (u *Updater) refreshTargets(ctx context.Context) (map[string][]string, []string, error) {
+	goModFiles, err := u.collectGoModFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	var tools map[string]bool
+	if u.ToolPolicy == ToolSkip {
+		if tools, err = u.toolModules(goModFiles); err != nil {
+			return nil, nil, fmt.Errorf("collecting tools: %w", err)
+		}
+	}
+
+	targets := map[string][]string{}
+	var moved []string
+	for _, gomod := range goModFiles {
+		graph, err := listModuleGraph(ctx, filepath.Dir(gomod))
+		if err != nil {
+			return nil, nil, err
+		}
+		parsed, err := u.parseGoMod(gomod)
+		if err != nil {
+			return nil, nil, err
+		}
+		excluded := map[string]struct{}{}
+		for _, ex := range parsed.Exclude {
+			excluded[ex.Mod.Path+"@"+ex.Mod.Version] = struct{}{}
+		}
+
+		for _, m := range graph {
+			if tools[m.Path] {
+				continue
+			}
+			mode := u.Refresh
+			strategy, err := u.strategy(m.Path)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch strategy {
+			case StrategyPinned:
+				continue
+			case StrategyPatch:
+				mode = RefreshPatch
+			}
+
+			next := refreshTarget(m, mode, excluded)
+			if next == "" {
+				continue
+			}
+			targets[gomod] = append(targets[gomod], fmt.Sprintf("%s@%s", m.Path, next))
+			moved = append(moved, fmt.Sprintf("%s %s => %s", m.Path, m.Version, next))
+		}
+	}
+	sort.Strings(moved)
+	return targets, moved, nil
+}
+
+// refreshDigest returns the version of a refresh, a digest of the versions the module graph moves to.
+func refreshDigest(targets map[string][]string) string {
+	unique := map[string]struct{}{}
+	for _, args := range targets {
+		for _, arg := range args {
+			unique[arg] = struct{}{}
+		}
+	}
+	versions := make([]string, 0, len(unique))
+	for v := range unique {
+		versions = append(versions, v)
+	}
+	return versionDigest(versions)
+}
+
+// refreshTarget returns the version a module moves to in a refresh, or "" if it does not move.
+func refreshTarget(m modinfo.ModulePublic, mode RefreshMode, excluded map[string]struct{}) string {
+	if m.Main || m.Error != nil || m.Replace != nil || m.Version == "" {
+		return ""
+	}
+	sameLine := semver.Major
+	if mode == RefreshPatch {
+		sameLine = semver.MajorMinor
+	}
+
+	next := m.Version
+	for _, v := range m.Versions {
+		if semver.Prerelease(v) != "" || sameLine(v) != sameLine(m.Version) {
+			continue
+		}
+		if _, ok := excluded[m.Path+"@"+v]; ok {
+			continue
+		}
+		if semver.Compare(v, next) > 0 {
+			next = v
+		}
+	}
+	if next == m.Version {
+		return ""
+	}
+	return next
+}
+
+// listModuleGraph returns every module in the build list of a module, with available versions.
+func listModuleGraph(ctx context.Context, dir string) ([]modinfo.ModulePublic, error) {
+	buf, errBuf, err := runWithScratchModFile(ctx, dir, "list", "-m", "-e", "-mod=mod", "-versions", "-json", "all")
+	if err != nil {
+		logrus.WithField("stderr", errBuf.String()).Warn("module graph query error")
+		return nil, fmt.Errorf("listing module graph: %w", err)
+	}
+
+	var graph []modinfo.ModulePublic
+	dec := json.NewDecoder(buf)
+	for {
+		var m modinfo.ModulePublic
+		if err := dec.Decode(&m); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding module graph: %w", err)
+		}
+		if m.Error != nil && !m.Main {
+			logrus.WithFields(logrus.Fields{
+				"path":  m.Path,
+				"error": m.Error.Err,
+			}).Warn("error listing module versions")
+		}
+		graph = append(graph, m)
+	}
+	return graph, nil
+}
+
+// applyRefresh refreshes the module graph of every module, and summarizes the modules that moved.
+func (u *Updater) applyRefresh(ctx context.Context, update updater.Update) error {
+	targets, _, err := u.refreshTargets(ctx)
+	if err != nil {
+		return err
+	}
+	// The refresh must match what was proposed, or the branch would not contain the versions it is named for:
+	if digest := refreshDigest(targets); digest != update.Next {
+		return fmt.Errorf("module graph refresh changed since check: expected %s, got %s", update.Next, digest)
+	}
+
+	goModFiles := make([]string, 0, len(targets))
+	for gomod := range targets {
+		goModFiles = append(goModFiles, gomod)
+	}
+	sort.Strings(goModFiles)
+	for _, gomod := range goModFiles {
+		modRoot := filepath.Dir(gomod)
+		beforeMod, beforeSum, err := u.moduleVersions(modRoot)
+		if err != nil {
+			return err
+		}
+
+		if err := u.refreshModule(ctx, modRoot, targets[gomod]); err != nil {
+			return err
+		}
+
+		afterMod, afterSum, err := u.moduleVersions(modRoot)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(u.root, gomod)
+		logrus.WithFields(logrus.Fields{
+			"gomod":   filepath.ToSlash(rel),
+			GoModFn:   movedModules(beforeMod, afterMod),
+			GoSumFn:   movedModules(beforeSum, afterSum),
+			"refresh": update.Next,
+		}).Info("refreshed module graph")
+	}
+	return nil
+}
+
+func (u *Updater) refreshModule(ctx context.Context, modRoot string, targets []string) error {
+	args := append([]string{"get"}, targets...)
+	if err := cmd.CommandExecute(ctx, modRoot, "go", args...); err != nil {
+		return fmt.Errorf("refreshing module graph: %w", err)
+	}
+	if u.Tidy {
+		if err := cmd.CommandExecute(ctx, modRoot, "go", "mod", "tidy"); err != nil {
+			return fmt.Errorf("tidying go.sum: %w", err)
+		}
+	}
+	if u.hasVendor(modRoot) {
+		if err := u.updateVendor(ctx, modRoot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moduleVersions returns the module versions required by a module's go.mod, and the newest version of each module in its go.sum.
+func (u *Updater) moduleVersions(modRoot string) (goMod, goSum map[string]string, err error) {
+	parsed, err := u.parseGoMod(filepath.Join(modRoot, GoModFn))
+	if err != nil {
+		return nil, nil, err
+	}
+	goMod = make(map[string]string, len(parsed.Require))
+	for _, req := range parsed.Require {
+		goMod[req.Mod.Path] = req.Mod.Version
+	}
+
+	goSum = map[string]string{}
+	f, err := os.Open(filepath.Join(modRoot, GoSumFn))
+	if os.IsNotExist(err) {
+		return goMod, goSum, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("opening go.sum: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		path, version := fields[0], strings.TrimSuffix(fields[1], "/go.mod")
+		if semver.Compare(version, goSum[path]) > 0 {
+			goSum[path] = version
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading go.sum: %w", err)
+	}
+	return goMod, goSum, nil
+}
+
+// movedModules summarizes the modules whose version changed.
+func movedModules(before, after map[string]string) []string {
+	var moved []string
+	for path, next := range after {
+		if prev := before[path]; prev != next {
+			if prev == "" {
+				prev = "(none)"
+			}
+			moved = append(moved, fmt.Sprintf("%s %s => %s", path, prev, next))
+		}
+	}
+	for path, prev := range before {
+		if _, ok := after[path]; !ok {
+			moved = append(moved, fmt.Sprintf("%s %s => (none)", path, prev))
+		}
+	}
+	sort.Strings(moved)
+	return moved
+}
@@ -0,0 +1,52 @@
+package gomodules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/thepwagner/action-update/cmd"
+)
+
+// releaseRepo is a temporary clone of a module's repository, used to compare commits.
+type releaseRepo struct {
+	dir     string
+	cleanup func()
+}
+
+func cloneReleaseRepo(ctx context.Context, url string) (*releaseRepo, error) {
+	dir, cleanup, err := scratchDir()
+	if err != nil {
+		return nil, err
+	}
+	// Only history is needed, not file contents:
+	if err := cmd.CommandExecute(ctx, dir, "git", "clone", "--quiet", "--bare", "--filter=blob:none", url, "."); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("cloning %s: %w", url, err)
+	}
+	return &releaseRepo{dir: dir, cleanup: cleanup}, nil
+}
+
+// HasCommit returns true if the repository contains a commit.
+func (r *releaseRepo) HasCommit(ctx context.Context, rev string) bool {
+	return exec.CommandContext(ctx, "git", "-C", r.dir, "cat-file", "-e", rev+"^{commit}").Run() == nil
+}
+
+// IsAncestor returns true if commit ancestor is reachable from commit descendant.
+func (r *releaseRepo) IsAncestor(ctx context.Context, ancestor, descendant string) (bool, error) {
+	err := exec.CommandContext(ctx, "git", "-C", r.dir, "merge-base", "--is-ancestor", ancestor, descendant).Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return false, nil
+	default:
+		return false, fmt.Errorf("comparing commits: %w", err)
+	}
+}
+
+func (r *releaseRepo) Close() {
+	r.cleanup()
+}
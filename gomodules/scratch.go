@@ -0,0 +1,83 @@
+package gomodules
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+var scratchModFile = []byte("module scratch\n")
+
+// runInScratchModule runs a go command in an empty, temporary module, e.g. to query module versions.
+func runInScratchModule(ctx context.Context, args ...string) (stdout, stderr *bytes.Buffer, err error) {
+	stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	dir, cleanup, err := scratchDir()
+	if err != nil {
+		return stdout, stderr, err
+	}
+	defer cleanup()
+	if err := ioutil.WriteFile(filepath.Join(dir, GoModFn), scratchModFile, 0600); err != nil {
+		return stdout, stderr, fmt.Errorf("writing scratch go.mod: %w", err)
+	}
+
+	cmd := scratchCommand(ctx, dir, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return stdout, stderr, cmd.Run()
+}
+
+// runWithScratchModFile runs a go command in a module, against temporary copies of its go.mod and go.sum files.
+// e.g. runWithScratchModFile(ctx, modRoot, "list", "-m", "all")
+func runWithScratchModFile(ctx context.Context, modRoot, command string, args ...string) (stdout, stderr *bytes.Buffer, err error) {
+	stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	dir, cleanup, err := scratchDir()
+	if err != nil {
+		return stdout, stderr, err
+	}
+	defer cleanup()
+	for _, fn := range []string{GoModFn, GoSumFn} {
+		b, err := ioutil.ReadFile(filepath.Join(modRoot, fn))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return stdout, stderr, fmt.Errorf("reading %s: %w", fn, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), b, 0600); err != nil {
+			return stdout, stderr, fmt.Errorf("writing scratch %s: %w", fn, err)
+		}
+	}
+
+	cmd := scratchCommand(ctx, modRoot, append([]string{command, "-modfile=" + filepath.Join(dir, GoModFn)}, args...)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return stdout, stderr, cmd.Run()
+}
+
+// scratchDir creates a temporary directory for scratch files, which are never written to the repository: `go` may
+// rewrite the go.mod and go.sum files of the module it runs in, and scaffolding left behind by a crash or cancellation
+// would be pushed with the update.
+func scratchDir() (string, func(), error) {
+	dir, err := ioutil.TempDir("", "action-update-go-")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating scratch dir: %w", err)
+	}
+	return dir, func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logrus.WithError(err).Warn("cleaning up scratch dir")
+		}
+	}, nil
+}
+
+// scratchCommand returns a go command outside of any workspace, which -modfile does not support.
+func scratchCommand(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+	return cmd
+}
@@ -0,0 +1,197 @@
+package gomodules
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// siblingModules returns the directories of modules within the repository, by module path.
+func (u *Updater) siblingModules(gomods []string) (map[string]string, error) {
+	siblings := make(map[string]string, len(gomods))
+	for _, gomod := range gomods {
+		parsed, err := u.parseGoMod(gomod)
+		if err != nil {
+			return nil, err
+		}
+		if parsed.Module != nil {
+			siblings[parsed.Module.Mod.Path] = filepath.Dir(gomod)
+		}
+	}
+	return siblings, nil
+}
+
+// siblingDependencies returns the requirements of a go.mod file that are replaced by a local path to a sibling module.
+func siblingDependencies(gomod string, parsed *modfile.File, siblings map[string]string) []updater.Dependency {
+	var deps []updater.Dependency
+	for _, req := range parsed.Require {
+		dir, ok := siblings[req.Mod.Path]
+		if !ok {
+			continue
+		}
+		rep := findReplacement(req.Mod.Path, req.Mod.Version, parsed.Replace)
+		if rep == nil || rep.New.Version != "" || localReplacementDir(gomod, rep) != dir {
+			continue
+		}
+		deps = append(deps, updater.Dependency{
+			Path:     req.Mod.Path,
+			Version:  req.Mod.Version,
+			Indirect: req.Indirect,
+		})
+	}
+	return deps
+}
+
+// localReplacementDir resolves the directory of a local path replacement in a go.mod file.
+func localReplacementDir(gomod string, rep *modfile.Replace) string {
+	dir := filepath.FromSlash(rep.New.Path)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(gomod), dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// checkSiblingModule proposes the newest tag of a sibling module, e.g. `common/v1.2.0` for the module in `common/`.
+func (u *Updater) checkSiblingModule(ctx context.Context, dep updater.Dependency, dir string, filter func(string) bool) (*updater.Update, error) {
+	rel, err := filepath.Rel(u.root, dir)
+	if err != nil {
+		return nil, err
+	}
+	var tagPrefix string
+	if rel != "." {
+		tagPrefix = filepath.ToSlash(rel) + "/"
+	}
+
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "tag", "--list", tagPrefix+"v*")
+	cmd.Dir = u.root
+	cmd.Stdout = &buf
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("listing sibling module tags: %w", err)
+	}
+
+	_, pathMajor, _ := module.SplitPathVersion(dep.Path)
+	var latest string
+	for _, tag := range strings.Fields(buf.String()) {
+		v := strings.TrimPrefix(tag, tagPrefix)
+		if !semver.IsValid(v) || module.CheckPathMajor(v, pathMajor) != nil || (filter != nil && !filter(v)) {
+			continue
+		}
+		if semver.Compare(latest, v) < 0 {
+			latest = v
+		}
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"path":            dep.Path,
+		"current_version": dep.Version,
+		"latest_version":  latest,
+	})
+	if latest == "" || semver.Compare(dep.Version, latest) >= 0 {
+		log.Debug("no sibling module update available")
+		return nil, nil
+	}
+	log.Info("sibling module update available")
+	return &updater.Update{
+		Path:     dep.Path,
+		Previous: dep.Version,
+		Next:     latest,
+	}, nil
+}
+
+// dropSiblingReplace removes the local replacement of an updated sibling module, when the base branch is a release branch.
+func (u *Updater) dropSiblingReplace(ctx context.Context, goMod *modfile.File, update updater.Update) error {
+	if len(u.ReleaseBranches) == 0 {
+		return nil
+	}
+	var local *modfile.Replace
+	for _, rep := range goMod.Replace {
+		if rep.Old.Path == update.Path && rep.New.Version == "" {
+			local = rep
+		}
+	}
+	if local == nil {
+		return nil
+	}
+
+	// The base branch is recorded by Dependencies, otherwise it is checked out or named by the update branch:
+	branch, suffix := u.baseBranch, ""
+	if branch == "" {
+		current, err := u.currentBranch(ctx)
+		if err != nil {
+			return err
+		}
+		branch = current
+		if strings.HasPrefix(current, updateBranchPrefix) {
+			branch, suffix = strings.TrimPrefix(current, updateBranchPrefix), "/**"
+		}
+	}
+	for _, pattern := range u.ReleaseBranches {
+		if m, _ := doublestar.Match(pattern+suffix, branch); m {
+			logrus.WithFields(logrus.Fields{
+				"path":   update.Path,
+				"branch": branch,
+			}).Info("dropping local replacement on release branch")
+			return goMod.DropReplace(local.Old.Path, local.Old.Version)
+		}
+	}
+	return nil
+}
+
+// updateBranchPrefix prefixes the branches updates are applied to, followed by the base branch.
+const updateBranchPrefix = "action-update-go/"
+
+// currentBranch returns the branch checked out in the repository, or "" if HEAD is detached.
+func (u *Updater) currentBranch(ctx context.Context) (string, error) {
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "symbolic-ref", "--quiet", "--short", "HEAD")
+	cmd.Dir = u.root
+	cmd.Stdout = &buf
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("resolving current branch: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// siblingModuleDir returns the directory of a module within the repository that is required through a local replacement, or "".
+func (u *Updater) siblingModuleDir(path string) (string, error) {
+	goModFiles, err := u.collectGoModFiles()
+	if err != nil {
+		return "", err
+	}
+	siblings, err := u.siblingModules(goModFiles)
+	if err != nil {
+		return "", err
+	}
+	dir, ok := siblings[path]
+	if !ok {
+		return "", nil
+	}
+	for _, gomod := range goModFiles {
+		parsed, err := u.parseGoMod(gomod)
+		if err != nil {
+			return "", err
+		}
+		for _, d := range siblingDependencies(gomod, parsed, siblings) {
+			if d.Path == path {
+				return dir, nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,302 @@
+package gomodules
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/semver"
+)
+
+// ReferenceKind identifies references to a module path, outside of imports, that are rewritten by major updates.
+type ReferenceKind string
+
+const (
+	// ReferenceDirectives are `//go:` directive comments in Go files, e.g. `//go:generate go run github.com/foo/bar/cmd/gen`
+	ReferenceDirectives ReferenceKind = "directives"
+	// ReferenceStrings are string literals in Go files
+	ReferenceStrings ReferenceKind = "strings"
+	// ReferenceProto are `go_package` options in .proto files
+	ReferenceProto ReferenceKind = "proto"
+	// ReferenceDocs are Markdown files, e.g. install instructions in README.md
+	ReferenceDocs ReferenceKind = "docs"
+)
+
+// sourceRewrite moves references from one module path to another.
+type sourceRewrite struct {
+	oldPath, newPath string
+	nextVersion      string
+	references       map[ReferenceKind]bool
+	referenceRE      *regexp.Regexp
+}
+
+func (u *Updater) newSourceRewrite(up updater.Update) *sourceRewrite {
+	rw := &sourceRewrite{
+		oldPath:     up.Path,
+		newPath:     pathMajorVersion(up.Path, semver.Major(up.Next)),
+		nextVersion: up.Next,
+		references:  make(map[ReferenceKind]bool, len(u.RewriteReferences)),
+		// The path, optionally followed by a subpackage and version, e.g. github.com/foo/bar/cmd/gen@v1.2.3
+		referenceRE: regexp.MustCompile(`(?:^|[^\w.-])(` + regexp.QuoteMeta(up.Path) + `(?:/[\w.~+-]+)*)(@[\w.+-]+)?`),
+	}
+	for _, kind := range u.RewriteReferences {
+		rw.references[kind] = true
+	}
+	return rw
+}
+
+// updateSourceCode rewrites imports within the packages of the given go.mod files.
+// If configured, other references to the path are also rewritten, and changed non-Go files are logged together.
+func (u *Updater) updateSourceCode(up updater.Update, goModFiles []string) error {
+	// replace foo.bar/v1 with foo.bar/v2 in imports:
+	rw := u.newSourceRewrite(up)
+
+	var otherFiles []string
+	for _, goModFile := range goModFiles {
+		modRoot := filepath.Dir(goModFile)
+		err := u.walkModule(modRoot, func(path string) error {
+			var changed bool
+			var err error
+			switch filepath.Ext(path) {
+			case ".go":
+				return updateSourceFile(path, rw)
+			case ".proto":
+				if rw.references[ReferenceProto] {
+					changed, err = rw.updateTextFile(path, goPackageRE)
+				}
+			case ".md":
+				if rw.references[ReferenceDocs] {
+					changed, err = rw.updateTextFile(path, nil)
+				}
+			}
+			if err != nil || !changed {
+				return err
+			}
+			rel, err := filepath.Rel(u.root, path)
+			if err != nil {
+				return err
+			}
+			otherFiles = append(otherFiles, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(otherFiles) > 0 {
+		sort.Strings(otherFiles)
+		logrus.WithFields(logrus.Fields{
+			"path":  up.Path,
+			"files": otherFiles,
+		}).Info("updated references in non-go files")
+	}
+	return nil
+}
+
+// walkModule visits files within a module's packages.
+// Vendored code, hidden directories and nested modules are skipped, as is testdata unless RewriteTestdata is set.
+func (u *Updater) walkModule(modRoot string, fn func(path string) error) error {
+	return filepath.Walk(modRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			logrus.WithError(err).WithField("path", path).Warn("error accessing path")
+			return err
+		}
+		if !info.IsDir() {
+			return fn(path)
+		}
+		if path == modRoot {
+			return nil
+		}
+
+		switch name := info.Name(); {
+		case name == "vendor", strings.HasPrefix(name, "."):
+			return filepath.SkipDir
+		case name == "testdata" && !u.RewriteTestdata:
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, GoModFn)); err == nil {
+			// Nested module, which is updated independently if it also requires the path
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+var majorSubPkgRE = regexp.MustCompile(`^/v\d+(/|$)`)
+
+// rewriteImportPath moves an import path from module oldPath to module newPath.
+// Returns false if the import path is not within oldPath.
+func rewriteImportPath(importPath, oldPath, newPath string) (string, bool) {
+	if importPath != oldPath && !strings.HasPrefix(importPath, oldPath+"/") {
+		return "", false
+	}
+	subPkg := importPath[len(oldPath):]
+	if !pathMajorVersionRE.MatchString(oldPath) && majorSubPkgRE.MatchString(subPkg) {
+		// Another major version of an unsuffixed path, e.g. github.com/foo/bar/v2 when updating github.com/foo/bar
+		return "", false
+	}
+	return newPath + subPkg, true
+}
+
+func updateSourceFile(srcFile string, rw *sourceRewrite) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, srcFile, nil, parser.ParseComments)
+	if err != nil {
+		logrus.WithField("file_path", srcFile).WithError(err).Warn("skipping unparseable source code file")
+		return nil
+	}
+
+	var changed bool
+	importLits := make(map[*ast.BasicLit]struct{}, len(f.Imports))
+	for _, imp := range f.Imports {
+		importLits[imp.Path] = struct{}{}
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if rewritten, ok := rewriteImportPath(importPath, rw.oldPath, rw.newPath); ok {
+			imp.Path.Value = strconv.Quote(rewritten)
+			changed = true
+		}
+	}
+
+	if rw.references[ReferenceDirectives] {
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if !strings.HasPrefix(c.Text, "//go:") {
+					continue
+				}
+				if rewritten, ok := rw.rewriteReferences(c.Text); ok {
+					c.Text = rewritten
+					changed = true
+				}
+			}
+		}
+	}
+
+	if rw.references[ReferenceStrings] {
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			if _, ok := importLits[lit]; ok {
+				return true
+			}
+			if rewritten, ok := rw.rewriteReferences(lit.Value); ok {
+				lit.Value = rewritten
+				changed = true
+			}
+			return true
+		})
+	}
+
+	if !changed {
+		return nil
+	}
+	logrus.WithField("file_path", srcFile).Debug("updating go file")
+
+	// Match gofmt, which sorts imports:
+	ast.SortImports(fset, f)
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return fmt.Errorf("formatting updated source: %w", err)
+	}
+	return writeFilePreservingMode(srcFile, buf.Bytes())
+}
+
+var goPackageRE = regexp.MustCompile(`^\s*option\s+go_package\s*=`)
+
+// updateTextFile rewrites references in lines of a file. If linePattern is provided, only matching lines are rewritten.
+func (rw *sourceRewrite) updateTextFile(path string, linePattern *regexp.Regexp) (bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("reading file: %w", err)
+	}
+
+	var changed bool
+	lines := strings.SplitAfter(string(b), "\n")
+	for i, line := range lines {
+		if linePattern != nil && !linePattern.MatchString(line) {
+			continue
+		}
+		if rewritten, ok := rw.rewriteReferences(line); ok {
+			lines[i] = rewritten
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	logrus.WithField("file_path", path).Debug("updating references")
+	return true, writeFilePreservingMode(path, []byte(strings.Join(lines, "")))
+}
+
+// rewriteReferences rewrites the path wherever it appears in s, with any version pinned to the next version.
+func (rw *sourceRewrite) rewriteReferences(s string) (string, bool) {
+	var buf strings.Builder
+	var last int
+	for _, m := range rw.referenceRE.FindAllStringSubmatchIndex(s, -1) {
+		refStart, refEnd, end := m[2], m[3], m[1]
+		if end < len(s) && continuesPath(s[end:]) {
+			// A longer path that shares a prefix, e.g. github.com/foo/bar/v20 when updating github.com/foo/bar/v2
+			continue
+		}
+		rewritten, ok := rewriteImportPath(s[refStart:refEnd], rw.oldPath, rw.newPath)
+		if !ok {
+			continue
+		}
+
+		buf.WriteString(s[last:refStart])
+		buf.WriteString(rewritten)
+		if m[4] >= 0 {
+			if version := s[m[4]+1 : m[5]]; semver.IsValid(version) {
+				buf.WriteString("@" + rw.nextVersion)
+			} else {
+				buf.WriteString(s[m[4]:m[5]])
+			}
+		}
+		last = end
+	}
+	if last == 0 {
+		return s, false
+	}
+	buf.WriteString(s[last:])
+	return buf.String(), true
+}
+
+// continuesPath returns true if s, which follows a reference, extends the path rather than terminating it.
+func continuesPath(s string) bool {
+	isWordChar := func(c byte) bool {
+		return c == '_' || c == '-' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+	}
+	if isWordChar(s[0]) {
+		return true
+	}
+	// Allow punctuation at the end of a sentence:
+	return s[0] == '.' && len(s) > 1 && isWordChar(s[1])
+}
+
+func writeFilePreservingMode(path string, b []byte) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat file: %w", err)
+	}
+	if err := ioutil.WriteFile(path, b, fi.Mode()); err != nil {
+		return fmt.Errorf("writing updated file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package gomodules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"gopkg.in/yaml.v3"
+)
+
+// StrategyConfigFn is the config file, relative to the root, that sets update strategies per module.
+const StrategyConfigFn = ".github/update-go.yaml"
+
+// Strategy limits the updates proposed for a module.
+type Strategy string
+
+const (
+	// StrategyPatch only proposes patch releases of the current minor version
+	StrategyPatch Strategy = "patch"
+	// StrategyMinor only proposes releases of the current major version
+	StrategyMinor Strategy = "minor"
+	// StrategyLatest proposes the latest release, including major versions unless MajorVersions is disabled
+	StrategyLatest Strategy = "latest"
+	// StrategyPinned never proposes updates
+	StrategyPinned Strategy = "pinned"
+)
+
+// StrategyConfig is the contents of StrategyConfigFn.
+type StrategyConfig struct {
+	Strategies []*StrategyRule `yaml:"strategies"`
+}
+
+// StrategyRule sets the strategy of modules matching a pattern.
+type StrategyRule struct {
+	// Pattern is a prefix for the module path, or a regular expression enclosed by /'s
+	Pattern  string   `yaml:"pattern"`
+	Strategy Strategy `yaml:"strategy"`
+
+	compiledPattern *regexp.Regexp
+}
+
+func (r *StrategyRule) Validate() error {
+	if r.Pattern == "" {
+		return fmt.Errorf("strategies must specify pattern")
+	}
+	switch r.Strategy {
+	case StrategyPatch, StrategyMinor, StrategyLatest, StrategyPinned:
+	default:
+		return fmt.Errorf("invalid strategy %q for pattern %q", r.Strategy, r.Pattern)
+	}
+
+	if strings.HasPrefix(r.Pattern, "/") && strings.HasSuffix(r.Pattern, "/") && len(r.Pattern) > 1 {
+		re, err := regexp.Compile(r.Pattern[1 : len(r.Pattern)-1])
+		if err != nil {
+			return fmt.Errorf("compiling pattern: %w", err)
+		}
+		r.compiledPattern = re
+	} else {
+		r.compiledPattern = regexp.MustCompile("^" + regexp.QuoteMeta(r.Pattern))
+	}
+	return nil
+}
+
+// parseStrategyConfig returns the strategy rules configured in the root, or nil if there is no config file.
+func (u *Updater) parseStrategyConfig() ([]*StrategyRule, error) {
+	b, err := ioutil.ReadFile(filepath.Join(u.root, filepath.FromSlash(StrategyConfigFn)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("opening strategy config: %w", err)
+	}
+
+	var cfg StrategyConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing strategy config: %w", err)
+	}
+	for _, rule := range cfg.Strategies {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid strategy config: %w", err)
+		}
+	}
+	return cfg.Strategies, nil
+}
+
+// loadStrategyRules parses the strategy config of the checked out branch, for the rest of the run.
+func (u *Updater) loadStrategyRules() error {
+	rules, err := u.parseStrategyConfig()
+	if err != nil {
+		return err
+	}
+	u.strategies, u.strategiesLoaded = rules, true
+	return nil
+}
+
+// strategyRules returns the strategy rules loaded for this run, parsing the config file if none were loaded.
+func (u *Updater) strategyRules() ([]*StrategyRule, error) {
+	if !u.strategiesLoaded {
+		if err := u.loadStrategyRules(); err != nil {
+			return nil, err
+		}
+	}
+	return u.strategies, nil
+}
+
+// strategy returns the strategy of the first rule matching a path, or "" if no rule matches.
+func (u *Updater) strategy(path string) (Strategy, error) {
+	rules, err := u.strategyRules()
+	if err != nil {
+		return "", err
+	}
+	for _, rule := range rules {
+		if rule.compiledPattern.MatchString(path) {
+			return rule.Strategy, nil
+		}
+	}
+	return "", nil
+}
+
+// strategyFilter wraps a version filter to also reject versions outside the strategy's range of the current version.
+func strategyFilter(filter func(string) bool, strategy Strategy, current string) func(string) bool {
+	var sameLine func(string) string
+	switch strategy {
+	case StrategyPatch:
+		sameLine = semver.MajorMinor
+	case StrategyMinor:
+		sameLine = semver.Major
+	default:
+		return filter
+	}
+	return func(v string) bool {
+		if sameLine(v) != sameLine(current) {
+			return false
+		}
+		return filter == nil || filter(v)
+	}
+}
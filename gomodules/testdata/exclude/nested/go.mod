@@ -0,0 +1,10 @@
+module github.com/thepwagner/action-update-go/exclude/nested
+
+go 1.15
+
+require github.com/pkg/errors v0.8.0
+
+exclude (
+	github.com/pkg/errors v0.7.0
+	github.com/pkg/errors v0.9.1
+)
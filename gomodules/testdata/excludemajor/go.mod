@@ -0,0 +1,7 @@
+module github.com/thepwagner/action-update-go/excludemajor
+
+go 1.15
+
+require example.com/refresh v1.0.0
+
+exclude example.com/refresh/v2 v2.0.0
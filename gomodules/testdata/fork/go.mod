@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/fork
+
+go 1.15
+
+require example.com/promo v1.0.0
@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/gorequirement
+
+go 1.20
+
+require example.com/gover v1.0.0
@@ -0,0 +1,3 @@
+module github.com/thepwagner/action-update-go/goversion
+
+go 1.20
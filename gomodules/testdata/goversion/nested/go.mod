@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/goversion/nested
+
+go 1.21.0
+
+toolchain go1.21.3
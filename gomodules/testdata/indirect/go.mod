@@ -0,0 +1,9 @@
+module github.com/thepwagner/action-update-go/indirect
+
+go 1.15
+
+require (
+	github.com/pkg/errors v0.8.0
+	github.com/sirupsen/logrus v1.5.0 // indirect
+	github.com/stretchr/testify v1.5.1 // indirect
+)
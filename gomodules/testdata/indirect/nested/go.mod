@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/indirect/nested
+
+go 1.15
+
+require github.com/sirupsen/logrus v1.6.0
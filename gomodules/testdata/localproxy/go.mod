@@ -0,0 +1,3 @@
+module github.com/thepwagner/action-update-go/localproxy
+
+go 1.15
@@ -0,0 +1,14 @@
+package main
+
+/*
+#include <stdlib.h>
+
+// import "github.com/caarlos0/env/v5"
+*/
+import "C"
+
+import (
+	"github.com/caarlos0/env/v5"
+)
+
+var cgoParse = env.Parse
@@ -0,0 +1,10 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"github.com/caarlos0/env/v50"
+)
+
+var lookalike = env.Parse
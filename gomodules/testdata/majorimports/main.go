@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+
+	environment "github.com/caarlos0/env/v5" // configuration
+)
+
+type cfg struct {
+	Name string `env:"NAME" envDefault:"World"`
+}
+
+func main() {
+	var c cfg
+	if err := environment.Parse(&c); err != nil {
+		panic(err)
+	}
+	fmt.Printf("hello %q\n", c.Name)
+}
@@ -0,0 +1,6 @@
+package main
+
+import "github.com/caarlos0/env/v5" // parse without options
+
+// Docs reference github.com/caarlos0/env/v5 in comments.
+var parse = env.Parse
@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/majorreferences
+
+go 1.15
+
+require github.com/caarlos0/env/v5 v5.1.4
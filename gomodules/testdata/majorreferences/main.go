@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/caarlos0/env/v5"
+)
+
+//go:generate go run github.com/caarlos0/env/v5/cmd/envgen@v5.1.4 -type cfg
+
+type cfg struct {
+	Name string `env:"NAME" envDefault:"World"`
+}
+
+const docs = "https://pkg.go.dev/github.com/caarlos0/env/v5"
+
+func main() {
+	var c cfg
+	if err := env.Parse(&c); err != nil {
+		panic(err)
+	}
+	fmt.Printf("hello %q, see %s\n", c.Name, docs)
+}
@@ -0,0 +1,7 @@
+module github.com/thepwagner/action-update-go/majorreplace
+
+go 1.15
+
+require example.com/env/v6 v6.2.0
+
+replace example.com/env/v6 => github.com/caarlos0/env/v6 v6.2.0
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/env/v6"
+)
+
+type cfg struct {
+	Name string `env:"NAME" envDefault:"World"`
+}
+
+func main() {
+	var c cfg
+	if err := env.Parse(&c); err != nil {
+		panic(err)
+	}
+	fmt.Printf("hello %q\n", c.Name)
+}
@@ -0,0 +1,8 @@
+package main
+
+import "github.com/caarlos0/env/v5"
+
+func main() {
+	var c struct{}
+	_ = env.Parse(&c)
+}
@@ -0,0 +1,5 @@
+package nested
+
+import "github.com/caarlos0/env/v5"
+
+var Parse = env.Parse
@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/nested/hidden
+
+go 1.15
+
+require github.com/stretchr/testify v1.6.1
@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/nested/services/api
+
+go 1.15
+
+require github.com/pkg/errors v0.8.0
@@ -0,0 +1,7 @@
+package main
+
+import "github.com/pkg/errors"
+
+func main() {
+	_ = errors.New("kaboom")
+}
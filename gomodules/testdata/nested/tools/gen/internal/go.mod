@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/nested/tools/gen/internal
+
+go 1.15
+
+require github.com/sirupsen/logrus v1.5.0
@@ -0,0 +1,7 @@
+package internal
+
+import "github.com/sirupsen/logrus"
+
+func Logger() logrus.FieldLogger {
+	return logrus.WithField("internal", true)
+}
@@ -0,0 +1,6 @@
+package pinned
+
+//go:generate go run example.com/tool/cmd/tool@v1.0.0 -out generated.go
+
+// Usage is not a directive, so it is not updated.
+const Usage = "go run example.com/tool/cmd/tool@v1.0.0"
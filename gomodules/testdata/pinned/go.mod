@@ -0,0 +1,3 @@
+module github.com/thepwagner/action-update-go/pinned
+
+go 1.18
@@ -0,0 +1,2 @@
+#!/bin/bash -e
+go run -mod=mod example.com/tool/cmd/tool@v1.0.0 ./...
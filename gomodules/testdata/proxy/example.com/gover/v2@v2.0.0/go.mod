@@ -0,0 +1,3 @@
+module example.com/gover/v2
+
+go 1.20
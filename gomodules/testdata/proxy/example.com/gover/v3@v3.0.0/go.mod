@@ -0,0 +1,3 @@
+module example.com/gover/v3
+
+go 1.22
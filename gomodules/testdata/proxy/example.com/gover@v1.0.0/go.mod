@@ -0,0 +1,3 @@
+module example.com/gover
+
+go 1.18
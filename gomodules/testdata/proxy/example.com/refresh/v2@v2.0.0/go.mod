@@ -0,0 +1 @@
+module example.com/refresh/v2
@@ -0,0 +1,3 @@
+package refresh
+
+func Hello() {}
@@ -0,0 +1 @@
+module github.com/thepwagner/action-update-go/siblings/common
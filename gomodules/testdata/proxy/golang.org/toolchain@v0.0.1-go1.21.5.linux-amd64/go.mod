@@ -0,0 +1 @@
+module golang.org/toolchain
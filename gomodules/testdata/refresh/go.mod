@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/refresh
+
+go 1.15
+
+require example.com/refresh v1.0.0
@@ -0,0 +1,7 @@
+package main
+
+import "example.com/refresh"
+
+func main() {
+	refresh.Hello()
+}
@@ -0,0 +1,7 @@
+module github.com/thepwagner/action-update-go/replaceversion
+
+go 1.15
+
+require github.com/pkg/errors v0.8.0
+
+replace github.com/pkg/errors v0.8.0 => github.com/thepwagner/errors v0.8.0
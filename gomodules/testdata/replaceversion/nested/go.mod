@@ -0,0 +1,8 @@
+module github.com/thepwagner/action-update-go/replaceversion/nested
+
+go 1.15
+
+require github.com/pkg/errors v0.8.0
+
+// Only applies to a version that isn't required:
+replace github.com/pkg/errors v0.7.0 => github.com/thepwagner/errors v0.8.0
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+func main() {
+	err := errors.New("kaboom")
+	fmt.Println(err)
+}
@@ -0,0 +1,3 @@
+module github.com/thepwagner/action-update-go/siblings/common
+
+go 1.15
@@ -0,0 +1,7 @@
+module github.com/thepwagner/action-update-go/siblings
+
+go 1.15
+
+require github.com/thepwagner/action-update-go/siblings/common v1.0.0
+
+replace github.com/thepwagner/action-update-go/siblings/common => ./common
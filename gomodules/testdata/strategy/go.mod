@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/strategy
+
+go 1.15
+
+require example.com/strategy v1.0.0
@@ -0,0 +1,10 @@
+module github.com/thepwagner/action-update-go/tools
+
+go 1.24
+
+tool example.com/tool/cmd/tool
+
+require (
+	example.com/lib v1.0.0
+	example.com/tool v1.0.0
+)
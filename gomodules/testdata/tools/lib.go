@@ -0,0 +1,7 @@
+package tools
+
+import "example.com/lib"
+
+func Hello() {
+	lib.Hello()
+}
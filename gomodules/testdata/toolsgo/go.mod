@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/toolsgo
+
+go 1.18
+
+require example.com/tool v1.0.0
@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/unalignedpseudo/a
+
+go 1.15
+
+require example.com/align v1.0.0
@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/unalignedpseudo/b
+
+go 1.15
+
+require example.com/align v1.1.1-0.20200101000000-abcdefabcdef
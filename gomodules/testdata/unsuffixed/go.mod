@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/unsuffixed
+
+go 1.15
+
+require github.com/urfave/cli v1.22.5
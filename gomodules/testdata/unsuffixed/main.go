@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/urfave/cli"
+)
+
+func main() {
+	var app cli.App
+	_ = app
+}
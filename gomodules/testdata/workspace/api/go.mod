@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/workspace/api
+
+go 1.18
+
+require github.com/pkg/errors v0.8.0
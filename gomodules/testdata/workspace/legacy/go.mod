@@ -0,0 +1,5 @@
+module github.com/thepwagner/action-update-go/workspace/legacy
+
+go 1.18
+
+require github.com/stretchr/testify v1.6.1
@@ -0,0 +1,8 @@
+module github.com/thepwagner/action-update-go/workspace/worker
+
+go 1.18
+
+require (
+	github.com/pkg/errors v0.8.0
+	github.com/sirupsen/logrus v1.5.0
+)
@@ -0,0 +1,239 @@
+package gomodules
+
+import (
+	"context"
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/cmd"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/modfile"
+)
+
+// ToolPolicy controls updates of tool dependencies, i.e. modules required by a `tool` directive or a tools.go file.
+// Nothing on a returned updater.Dependency marks it as a tool, so tools can't be grouped apart from other modules:
+// the policy only skips tools, or selects them alone e.g. for a separate workflow that updates tools on their own schedule.
+type ToolPolicy string
+
+const (
+	// ToolInclude updates tool dependencies like any other
+	ToolInclude ToolPolicy = "include"
+	// ToolSkip never updates tool dependencies
+	ToolSkip ToolPolicy = "skip"
+	// ToolOnly updates tool dependencies, and nothing else
+	ToolOnly ToolPolicy = "only"
+)
+
+// toolsBuildTag is the build constraint of files that track tools with blank imports, e.g. `//go:build tools`.
+const toolsBuildTag = "tools"
+
+// filterTools applies the tool dependency policy to dependencies.
+func (u *Updater) filterTools(deps map[string]*requirement, tools map[string]bool) map[string]*requirement {
+	switch u.ToolPolicy {
+	case ToolSkip, ToolOnly:
+	default:
+		return deps
+	}
+
+	filtered := make(map[string]*requirement, len(deps))
+	for key, req := range deps {
+		if tools[req.Path] == (u.ToolPolicy == ToolOnly) {
+			filtered[key] = req
+		}
+	}
+	return filtered
+}
+
+// toolModules returns the paths of modules that provide tools to any of the go.mod files.
+func (u *Updater) toolModules(gomods []string) (map[string]bool, error) {
+	ret := map[string]bool{}
+	for _, gomod := range gomods {
+		tools, err := u.moduleTools(gomod)
+		if err != nil {
+			return nil, err
+		}
+		for path := range tools {
+			ret[path] = true
+		}
+	}
+	return ret, nil
+}
+
+// moduleTools returns the tool packages of a go.mod file, by the path of the module that provides them.
+func (u *Updater) moduleTools(gomod string) (map[string][]string, error) {
+	parsed, err := u.parseGoMod(gomod)
+	if err != nil {
+		return nil, err
+	}
+	work, err := u.parseGoWork()
+	if err != nil {
+		return nil, err
+	}
+	var workReplace []*modfile.Replace
+	if work != nil {
+		workReplace = work.Replace
+	}
+
+	pkgs := make([]string, 0, len(parsed.Tool))
+	for _, tool := range parsed.Tool {
+		pkgs = append(pkgs, tool.Path)
+	}
+	imported, err := u.toolsFileImports(filepath.Dir(gomod))
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, imported...)
+
+	tools := map[string][]string{}
+	for _, pkg := range pkgs {
+		req := providingRequirement(parsed, pkg)
+		if req == nil {
+			logrus.WithFields(logrus.Fields{"package": pkg, "go.mod": gomod}).Debug("tool is not provided by a requirement")
+			continue
+		}
+		path := req.Mod.Path
+		if replacement := findReplacement(req.Mod.Path, req.Mod.Version, parsed.Replace, workReplace); replacement != nil {
+			if replacement.New.Version == "" {
+				continue
+			}
+			path = replacement.New.Path
+		}
+		tools[path] = append(tools[path], pkg)
+	}
+	for _, p := range tools {
+		sort.Strings(p)
+	}
+	return tools, nil
+}
+
+// providingRequirement returns the requirement with the longest path that provides a package.
+func providingRequirement(parsed *modfile.File, pkg string) *modfile.Require {
+	var ret *modfile.Require
+	for _, req := range parsed.Require {
+		if pkg != req.Mod.Path && !strings.HasPrefix(pkg, req.Mod.Path+"/") {
+			continue
+		}
+		if ret == nil || len(req.Mod.Path) > len(ret.Mod.Path) {
+			ret = req
+		}
+	}
+	return ret
+}
+
+// toolsFileImports returns the blank imports of Go files constrained to the tools build tag, within a module.
+func (u *Updater) toolsFileImports(modRoot string) ([]string, error) {
+	var imports []string
+	err := filepath.Walk(modRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path == modRoot {
+				return nil
+			}
+			if u.skipDir(path) {
+				return filepath.SkipDir
+			}
+			// Nested modules track their own tools:
+			if _, err := os.Stat(filepath.Join(path, GoModFn)); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading go file: %w", err)
+		}
+		if !isToolsFile(src) {
+			return nil
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), path, src, parser.ImportsOnly)
+		if err != nil {
+			logrus.WithField("file_path", path).WithError(err).Warn("skipping unparseable tools file")
+			return nil
+		}
+		for _, imp := range f.Imports {
+			if imp.Name == nil || imp.Name.Name != "_" {
+				continue
+			}
+			if pkg, err := strconv.Unquote(imp.Path.Value); err == nil {
+				imports = append(imports, pkg)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collecting tools files: %w", err)
+	}
+	return imports, nil
+}
+
+// isToolsFile returns true if Go source is only built with the tools build tag, e.g. `//go:build tools`.
+func isToolsFile(src []byte) bool {
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if !constraint.IsGoBuild(line) && !constraint.IsPlusBuild(line) {
+			continue
+		}
+		expr, err := constraint.Parse(line)
+		if err != nil {
+			continue
+		}
+		withTools := expr.Eval(func(tag string) bool { return tag == toolsBuildTag })
+		without := expr.Eval(func(string) bool { return false })
+		return withTools && !without
+	}
+	return false
+}
+
+// verifyTools builds the tools provided by an updated module, in each go.mod file that uses them.
+func (u *Updater) verifyTools(ctx context.Context, update updater.Update, modFiles []string) error {
+	path := updateModulePath(update)
+	for _, gomod := range modFiles {
+		tools, err := u.moduleTools(gomod)
+		if err != nil {
+			return err
+		}
+		pkgs := tools[path]
+		if len(pkgs) == 0 {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"go.mod": gomod,
+			"tools":  pkgs,
+		}).Info("verifying tools")
+		if err := buildTools(ctx, filepath.Dir(gomod), pkgs); err != nil {
+			return fmt.Errorf("building tools of %s: %w", gomod, err)
+		}
+	}
+	return nil
+}
+
+// buildTools builds tool packages within a module, discarding the binaries.
+func buildTools(ctx context.Context, modRoot string, pkgs []string) error {
+	outDir, cleanup, err := scratchDir()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	args := append([]string{"build", "-o", outDir + string(filepath.Separator)}, pkgs...)
+	return cmd.CommandExecute(ctx, modRoot, "go", args...)
+}
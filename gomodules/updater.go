@@ -1,6 +1,8 @@
 package gomodules
 
 import (
+	"time"
+
 	"github.com/thepwagner/action-update/updater"
 	"golang.org/x/mod/semver"
 )
@@ -10,8 +12,54 @@ type Updater struct {
 
 	// MajorVersion attempts major package versions, e.g. github.com/foo/bar/v2 -> github.com/foo/bar/v3
 	MajorVersions bool
+	// MaxMajorJump limits how many major versions a single update may advance, 0 is unlimited
+	MaxMajorJump int
 	// Tidy toggles `go mod tidy` after an update
 	Tidy bool
+	// RewriteReferences are kinds of non-import references that are also rewritten by major updates
+	RewriteReferences []ReferenceKind
+	// RewriteTestdata includes testdata directories when rewriting source code for major updates
+	RewriteTestdata bool
+	// AlignVersions proposes a single version for each path, shared by every go.mod file that requires it
+	AlignVersions bool
+	// Exclude is a list of glob patterns, relative to the root, for directories that are not searched for go.mod files
+	Exclude []string
+	// PseudoVersions proposes newer commits for dependencies pinned to a pseudo-version
+	PseudoVersions bool
+	// PseudoVersionBranch is the branch tracked by pseudo-versions, the default branch if empty
+	PseudoVersionBranch string
+	// PseudoVersionMinAge is how old a commit must be before it is proposed as a pseudo-version
+	PseudoVersionMinAge time.Duration
+	// PromotePseudoVersions proposes tagged releases that contain the commit of a pseudo-version
+	PromotePseudoVersions bool
+	// DropStaleExcludes removes exclude directives for versions older than an update
+	DropStaleExcludes bool
+	// TrackForks proposes dropping replacements by forks once upstream releases a version containing the fork
+	TrackForks bool
+	// SiblingModules updates requirements of modules in the repository that are replaced by a local path
+	SiblingModules bool
+	// ReleaseBranches are glob patterns for base branches where sibling module updates drop the local replacement
+	ReleaseBranches []string
+	// IndirectPolicy controls updates of indirect dependencies, the default includes them
+	IndirectPolicy IndirectPolicy
+	// Refresh adds a RefreshBatchPath dependency, that moves the entire module graph to the latest patch or minor versions
+	Refresh RefreshMode
+	// GoVersion adds a GoVersionPath dependency, that updates go.mod directives, Dockerfiles and workflows to new Go releases
+	GoVersion bool
+	// PinnedTools includes tools pinned by `go install pkg@version` and `go run pkg@version` as dependencies
+	PinnedTools bool
+	// GoVersionPolicy controls updates to versions that declare a newer Go version, the default ignores it
+	GoVersionPolicy GoVersionPolicy
+	// ToolPolicy controls updates of modules that provide tools, the default includes them
+	ToolPolicy ToolPolicy
+	// VerifyTools builds the tools provided by an updated module
+	VerifyTools bool
+
+	strategies       []*StrategyRule
+	strategiesLoaded bool
+	pinnedModules    map[pinnedReference]string
+	forkProposals    map[updater.Update]forkProposal
+	baseBranch       string
 }
 
 var _ updater.Updater = (*Updater)(nil)
@@ -22,8 +70,11 @@ func NewUpdater(root string, opts ...UpdaterOpt) *Updater {
 	u := &Updater{
 		root: root,
 
-		MajorVersions: true,
-		Tidy:          true,
+		MajorVersions:   true,
+		Tidy:            true,
+		IndirectPolicy:  IndirectInclude,
+		GoVersionPolicy: GoVersionIgnore,
+		ToolPolicy:      ToolInclude,
 	}
 	for _, opt := range opts {
 		opt(u)
@@ -39,18 +90,153 @@ func WithTidy(tidy bool) UpdaterOpt {
 	}
 }
 
+func WithRewriteTestdata(rewrite bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.RewriteTestdata = rewrite
+	}
+}
+
+func WithRewriteReferences(kinds ...ReferenceKind) UpdaterOpt {
+	return func(u *Updater) {
+		u.RewriteReferences = kinds
+	}
+}
+
 func WithMajorVersions(major bool) UpdaterOpt {
 	return func(u *Updater) {
 		u.MajorVersions = major
 	}
 }
 
+func WithMaxMajorJump(max int) UpdaterOpt {
+	return func(u *Updater) {
+		u.MaxMajorJump = max
+	}
+}
+
+func WithAlignVersions(align bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.AlignVersions = align
+	}
+}
+
+func WithExclude(patterns ...string) UpdaterOpt {
+	return func(u *Updater) {
+		u.Exclude = patterns
+	}
+}
+
+func WithPseudoVersions(pseudo bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.PseudoVersions = pseudo
+	}
+}
+
+func WithPseudoVersionBranch(branch string) UpdaterOpt {
+	return func(u *Updater) {
+		u.PseudoVersionBranch = branch
+	}
+}
+
+func WithPseudoVersionMinAge(age time.Duration) UpdaterOpt {
+	return func(u *Updater) {
+		u.PseudoVersionMinAge = age
+	}
+}
+
+func WithPromotePseudoVersions(promote bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.PromotePseudoVersions = promote
+	}
+}
+
+func WithDropStaleExcludes(drop bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.DropStaleExcludes = drop
+	}
+}
+
+func WithTrackForks(track bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.TrackForks = track
+	}
+}
+
+func WithSiblingModules(siblings bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.SiblingModules = siblings
+	}
+}
+
+func WithReleaseBranches(patterns ...string) UpdaterOpt {
+	return func(u *Updater) {
+		u.ReleaseBranches = patterns
+	}
+}
+
+func WithIndirectPolicy(policy IndirectPolicy) UpdaterOpt {
+	return func(u *Updater) {
+		u.IndirectPolicy = policy
+	}
+}
+
+func WithRefresh(mode RefreshMode) UpdaterOpt {
+	return func(u *Updater) {
+		u.Refresh = mode
+	}
+}
+
+func WithGoVersion(goVersion bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.GoVersion = goVersion
+	}
+}
+
+func WithGoVersionPolicy(policy GoVersionPolicy) UpdaterOpt {
+	return func(u *Updater) {
+		u.GoVersionPolicy = policy
+	}
+}
+
+func WithPinnedTools(pinned bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.PinnedTools = pinned
+	}
+}
+
+func WithToolPolicy(policy ToolPolicy) UpdaterOpt {
+	return func(u *Updater) {
+		u.ToolPolicy = policy
+	}
+}
+
+func WithVerifyTools(verify bool) UpdaterOpt {
+	return func(u *Updater) {
+		u.VerifyTools = verify
+	}
+}
+
 const (
 	GoModFn         = "go.mod"
 	GoSumFn         = "go.sum"
+	GoWorkFn        = "go.work"
+	GoWorkSumFn     = "go.work.sum"
 	VendorModulesFn = "vendor/modules.txt"
 )
 
+// MajorPkg returns true if an update changes the module path, e.g. github.com/foo/bar/v2 -> github.com/foo/bar/v3.
 func MajorPkg(u updater.Update) bool {
-	return semver.Major(u.Previous) != semver.Major(u.Next) && pathMajorVersionRE.MatchString(u.Path)
+	if pathMajorVersionRE.MatchString(u.Path) {
+		return semver.Major(u.Previous) != semver.Major(u.Next)
+	}
+
+	// Paths without a suffix move to one when leaving v0/v1, unless the next version is also +incompatible:
+	switch semver.Major(u.Next) {
+	case "v0", "v1":
+		return false
+	}
+	if semver.Build(u.Next) == "+incompatible" {
+		return false
+	}
+	return semver.Major(u.Previous) != semver.Major(u.Next) || semver.Build(u.Previous) == "+incompatible"
 }
@@ -47,14 +47,6 @@ func TestMajorPkg(t *testing.T) {
 				assert.True(t, gomodules.MajorPkg(u), v)
 			}
 
-			for _, v := range c.major {
-				u := updater.Update{
-					Path:     "github.com/foo/bar",
-					Previous: baseVersion,
-					Next:     v,
-				}
-				assert.False(t, gomodules.MajorPkg(u), v)
-			}
 			for _, v := range c.notMajor {
 				u := updater.Update{
 					Path:     updatePath,
@@ -66,3 +58,27 @@ func TestMajorPkg(t *testing.T) {
 		})
 	}
 }
+
+func TestMajorPkg_Unsuffixed(t *testing.T) {
+	cases := []struct {
+		previous, next string
+		major          bool
+	}{
+		{previous: "v1.9.0", next: "v2.0.0", major: true},
+		{previous: "v0.3.0", next: "v2.1.0", major: true},
+		{previous: "v1.9.0", next: "v1.10.0", major: false},
+		{previous: "v0.3.0", next: "v1.0.0", major: false},
+		{previous: "v2.0.0+incompatible", next: "v3.0.0+incompatible", major: false},
+		{previous: "v2.0.0+incompatible", next: "v3.0.0", major: true},
+		{previous: "v2.1.0+incompatible", next: "v2.2.0", major: true},
+	}
+
+	for _, c := range cases {
+		u := updater.Update{
+			Path:     "github.com/foo/bar",
+			Previous: c.previous,
+			Next:     c.next,
+		}
+		assert.Equal(t, c.major, gomodules.MajorPkg(u), "%s -> %s", c.previous, c.next)
+	}
+}
@@ -0,0 +1,86 @@
+package gomodules
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thepwagner/action-update/cmd"
+	"github.com/thepwagner/action-update/updater"
+	"golang.org/x/mod/modfile"
+)
+
+// parseGoWork returns the go.work file in the root, or nil if the root is not a workspace.
+func (u *Updater) parseGoWork() (*modfile.WorkFile, error) {
+	path := filepath.Join(u.root, GoWorkFn)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("opening go.work: %w", err)
+	}
+	parsed, err := modfile.ParseWork(GoWorkFn, b, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing go.work: %w", err)
+	}
+	return parsed, nil
+}
+
+// workspaceGoModFiles returns the go.mod files of modules used by a workspace.
+func (u *Updater) workspaceGoModFiles(work *modfile.WorkFile) []string {
+	gomods := make([]string, 0, len(work.Use))
+	for _, use := range work.Use {
+		dir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(u.root, dir)
+		}
+		gomods = append(gomods, filepath.Join(dir, GoModFn))
+	}
+	return gomods
+}
+
+// updateGoWork updates replacements of the go.work file, and regenerates go.work.sum for the updated workspace.
+// Modules used by the workspace are updated individually, so `go work sync` is not run.
+func (u *Updater) updateGoWork(ctx context.Context, work *modfile.WorkFile, update updater.Update) error {
+	changed, err := patchParsedGoWork(work, update)
+	if err != nil {
+		return err
+	}
+	if changed {
+		updated := modfile.Format(work.Syntax)
+		if err := ioutil.WriteFile(filepath.Join(u.root, GoWorkFn), updated, 0644); err != nil {
+			return fmt.Errorf("writing updated go.work: %w", err)
+		}
+	}
+
+	// Regenerate go.work.sum from scratch, so checksums for previous versions are dropped.
+	// Downloading `all` records checksums the workspace needs that are missing from each module's go.sum:
+	workSum := filepath.Join(u.root, GoWorkSumFn)
+	if err := os.Remove(workSum); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing go.work.sum: %w", err)
+	}
+	if err := cmd.CommandExecute(ctx, u.root, "go", "mod", "download", "all"); err != nil {
+		return fmt.Errorf("updating go.work.sum: %w", err)
+	}
+	return nil
+}
+
+// patchParsedGoWork updates go.work replacements by the updated path, returning true if any changed.
+func patchParsedGoWork(work *modfile.WorkFile, update updater.Update) (bool, error) {
+	for _, rep := range work.Replace {
+		if rep.New.Path == update.Path {
+			if rep.New.Version == update.Next {
+				return false, nil
+			}
+			logrus.WithField("path", rep.Old.Path).Debug("updating go.work replacement")
+			if err := work.AddReplace(rep.Old.Path, rep.Old.Version, update.Path, update.Next); err != nil {
+				return false, fmt.Errorf("adding workspace replacement: %w", err)
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
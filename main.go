@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 
+	parser "github.com/caarlos0/env/v6"
 	"github.com/sirupsen/logrus"
 	"github.com/thepwagner/action-update-go/gomodules"
 	"github.com/thepwagner/action-update/actions/updateaction"
@@ -15,9 +16,19 @@ func main() {
 
 	ctx := context.Background()
 
+	// Parse and validate the inputs once, before handling the event:
 	var env gomodules.Environment
+	if err := parser.Parse(&env); err != nil {
+		logrus.WithError(err).Fatal("parsing environment")
+	}
+	if err := env.Validate(); err != nil {
+		logrus.WithError(err).Fatal("failed")
+	}
+	actionEnv := &env.Environment.Environment
+	logrus.SetLevel(actionEnv.LogLevel())
+
 	handlers := updateaction.NewHandlers(&env)
-	if err := handlers.ParseAndHandle(ctx, &env); err != nil {
+	if err := handlers.Handle(ctx, actionEnv); err != nil {
 		logrus.WithError(err).Fatal("failed")
 	}
 }